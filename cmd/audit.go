@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/audit"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+var auditIPsCmd = &cobra.Command{
+	Use:   "audit-ips",
+	Short: "Report Network IP addresses in use by virtual machines and virtual machines outside their subnet",
+	Run: func(cmd *cobra.Command, args []string) {
+		pools, networks, err := generation.ReadManifests(ManifestDir)
+		if err != nil {
+			log.Fatalf("unable to read manifests: %v", err)
+		}
+
+		vcenterCredentials, err := vsphereCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		vmeta := newVSphereMetadata()
+		conflicts, err := auditIPs(vmeta, vcenterCredentials, pools, networks)
+		vmeta.Logout()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch OutputFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(conflicts)
+		default:
+			err = audit.WriteConflicts(os.Stdout, conflicts)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var OutputFormat string
+
+// auditIPs returns the ip conflicts of the Networks on every vCenter
+func auditIPs(vmeta *vsphere.Metadata, vcenterCredentials map[string]vsphere.VCenterCredential, pools []vcmv1.Pool, networks []vcmv1.Network) ([]audit.Conflict, error) {
+	conflicts := make([]audit.Conflict, 0)
+	for k, v := range vcenterCredentials {
+		if _, err := vmeta.AddVCenterCredential(k, v); err != nil {
+			return nil, err
+		}
+
+		serverConflicts, err := audit.IPConflicts(vmeta, k, pools, networks)
+		if err != nil {
+			return nil, fmt.Errorf("unable to audit %s: %w", k, err)
+		}
+		conflicts = append(conflicts, serverConflicts...)
+	}
+	return conflicts, nil
+}
+
+func init() {
+	auditIPsCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	auditIPsCmd.Flags().StringVarP(&ManifestDir, "manifests", "m", "./manifests", "Path of the previously generated manifests")
+	auditIPsCmd.Flags().StringVarP(&OutputFormat, "output", "o", "table", "Output format, table or json")
+	addSessionFlags(auditIPsCmd)
+	addCredentialFlags(auditIPsCmd)
+
+	rootCmd.AddCommand(auditIPsCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the vcmd configuration file",
+	// the file is validated by the subcommands instead of loaded
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [FILE]",
+	Short: "Validate the configuration file, exits non-zero on a violation",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := configFileName()
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name == "" {
+			log.Fatalf("no configuration file, set --config or create %s", config.DefaultFileName)
+		}
+
+		if _, err := config.Load(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", name)
+	},
+}
+
+var ConfigFileName string
+
+// Configuration the loaded configuration file, nil without one
+var Configuration *config.Config
+
+// configFileName returns the --config file, VCMD_CONFIG, or vcmd.yaml when it exists
+func configFileName() string {
+	if ConfigFileName != "" {
+		return ConfigFileName
+	}
+	if name := os.Getenv("VCMD_CONFIG"); name != "" {
+		return name
+	}
+	if _, err := os.Stat(config.DefaultFileName); err == nil {
+		return config.DefaultFileName
+	}
+	return ""
+}
+
+// loadConfig loads the configuration file and sets the flags of the command not set on the command line
+func loadConfig(cmd *cobra.Command, args []string) {
+	name := configFileName()
+	if name == "" {
+		return
+	}
+
+	c, err := config.Load(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Configuration = c
+
+	for flagName, value := range c.Flags() {
+		f := cmd.Flags().Lookup(flagName)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			log.Fatalf("%s: invalid %s: %v", name, flagName, err)
+		}
+	}
+}
+
+// configSettings returns the settings of the configuration file, or the defaults
+func configSettings() *generation.Settings {
+	if Configuration != nil {
+		return Configuration.Settings()
+	}
+	return generation.DefaultSettings()
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&ConfigFileName, "config", "", "Configuration file, defaults to VCMD_CONFIG or "+config.DefaultFileName+" when it exists")
+	rootCmd.PersistentPreRun = loadConfig
+
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/credentials"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
 )
 
 var rootCmd = &cobra.Command{
@@ -30,34 +36,195 @@ var generateCmd = &cobra.Command{
 			log.Fatalf("Manifest directory is not empty, please ensure %s is empty and run 'vcmd generate'", ManifestDir)
 		}
 
-		assets, err := generation.CreateVSphereEnvironmentsConfig(VCenterAuthFileName, IBMCloudAuthFileName, IPv6Subnet, PortGroupNameSubstring)
+		ttls, err := cache.ParseTTLs(CacheTTLs)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var c *cache.Cache
+		if CacheDir != "" {
+			c, err = cache.New(CacheDir, ttls, Refresh)
+			if err != nil {
+				log.Fatalf("unable to create cache: %v", err)
+			}
+		}
+
+		assets, report, err := generation.CreateVSphereEnvironmentsConfig(generation.Options{
+			VCenterAuthFileName:       VCenterAuthFileName,
+			IBMCloudAuthFileName:      IBMCloudAuthFileName,
+			Credentials:               credentialChain(),
+			Settings:                  configSettings(),
+			IPv6Subnet:                IPv6Subnet,
+			PortGroupNameSubstring:    PortGroupNameSubstring,
+			IPAddressCount:            IPAddressCount,
+			VIPPairs:                  VIPPairs,
+			SubnetTypes:               SubnetTypes,
+			IPv6Strategies:            IPv6Strategies,
+			IPv6StaticFileName:        IPv6StaticFileName,
+			PageSize:                  PageSize,
+			Cache:                     c,
+			LocationStrategies:        LocationStrategies,
+			LocationOverridesFileName: LocationOverridesFileName,
+			HostInventory:             HostInventory,
+			Costs:                     CostReportPrefix != "",
+			ReservationsFileName:      ReservationsFileName,
+			TagLayout:                 tagLayout(),
+			VSphereSessionCacheDir:    VSphereSessionCacheDir,
+			VSphereKeepAlive:          VSphereKeepAlive,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		log.Printf("writing %d assets to %s", len(assets), ManifestDir)
 		for _, asset := range assets {
-			err = generation.WriteManifest(asset.Asset, ManifestDir, asset.FileName)
+			err = generation.RenderManifest(asset.Asset, ManifestDir, asset.FileName, ManifestFormat)
 			if err != nil {
 				log.Fatalf("unable to write manifests: %v", err)
 			}
 		}
 
+		report.Log()
+		if ReportFileName != "" {
+			if err := generation.WriteReport(report, ReportFileName); err != nil {
+				log.Fatalf("unable to write report: %v", err)
+			}
+		}
+		if CostReportPrefix != "" {
+			if err := generation.WriteCostReport(report.Costs, CostReportPrefix); err != nil {
+				log.Fatalf("unable to write cost report: %v", err)
+			}
+		}
+
 	},
 }
 
 var VCenterAuthFileName string
 var IBMCloudAuthFileName string
 var ManifestDir string
+var ManifestFormat string
 var IPv6Subnet string
 var PortGroupNameSubstring string
+var IPAddressCount int
+var VIPPairs int
+var SubnetTypes []string
+var IPv6Strategies []string
+var IPv6StaticFileName string
+var PageSize int
+var CacheDir string
+var CacheTTLs map[string]string
+var Refresh bool
+var ReportFileName string
+var LocationStrategies []string
+var LocationOverridesFileName string
+var HostInventory bool
+var CostReportPrefix string
+var ReservationsFileName string
+var RegionCategory string
+var ZoneCategory string
+var RegionTypes []string
+var ZoneTypes []string
+var VSphereSessionCacheDir string
+var VSphereKeepAlive time.Duration
+var CredentialProviders []string
+var CredentialHelper string
+var CredentialSecret string
+
+// addTagLayoutFlags registers the flags of the region and zone tag categories and tagged object types
+func addTagLayoutFlags(cmd *cobra.Command) {
+	layout := vsphere.DefaultTagLayout()
+	cmd.Flags().StringVar(&RegionCategory, "region-category", layout.RegionCategory, "Tag category of regions")
+	cmd.Flags().StringVar(&ZoneCategory, "zone-category", layout.ZoneCategory, "Tag category of zones")
+	cmd.Flags().StringSliceVar(&RegionTypes, "region-types", []string{string(vsphere.RegionTypeDatacenter)}, "Objects region tags are read from: "+strings.Join(vsphere.RegionTypeNames(), ", "))
+	cmd.Flags().StringSliceVar(&ZoneTypes, "zone-types", []string{string(vsphere.ZoneTypeComputeCluster)}, "Objects zone tags are read from: "+strings.Join(vsphere.ZoneTypeNames(), ", "))
+}
+
+// addSessionFlags registers the flags of the vCenter session cache and keep alive
+func addSessionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&VSphereSessionCacheDir, "session-cache-dir", "", "Optional directory persisting vCenter sessions between runs, sessions are not logged out")
+	cmd.Flags().DurationVar(&VSphereKeepAlive, "keepalive", 0, "Idle time between vCenter keep alive requests, e.g. 5m, 0 disables them")
+}
+
+// addCredentialFlags registers the flags of the credential provider chain
+func addCredentialFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&CredentialProviders, "credential-providers", credentials.DefaultProviders, "Credential providers tried in order: "+strings.Join(credentials.Providers, ", "))
+	cmd.Flags().StringVar(&CredentialHelper, "credential-helper", "", "Optional command printing the vCenter and IBM Cloud credentials as JSON")
+	cmd.Flags().StringVar(&CredentialSecret, "credential-secret", "", "Optional [namespace/]name of the Kubernetes Secret holding the credentials")
+	addKeyFlags(cmd)
+}
+
+// credentialChain returns the credential provider chain of the flags
+func credentialChain() *credentials.Chain {
+	chain, err := credentials.NewChain(credentials.Options{
+		Providers:            CredentialProviders,
+		VCenterAuthFileName:  VCenterAuthFileName,
+		IBMCloudAuthFileName: IBMCloudAuthFileName,
+		HelperCommand:        CredentialHelper,
+		Secret:               CredentialSecret,
+		Keys:                 keys(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return chain
+}
+
+// vsphereCredentials returns the selected vCenter credentials resolved by the provider chain of the flags
+func vsphereCredentials() (map[string]vsphere.VCenterCredential, error) {
+	credentials, err := credentialChain().VSphere(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return configSettings().ApplyVCenters(credentials), nil
+}
+
+// newVSphereMetadata creates the vSphere metadata with the tag layout and session settings of the flags
+func newVSphereMetadata() *vsphere.Metadata {
+	vmeta := vsphere.NewMetadata()
+	vmeta.TagLayout = *tagLayout()
+	vmeta.SessionCacheDir = VSphereSessionCacheDir
+	vmeta.KeepAlive = VSphereKeepAlive
+	if PortGroupNameSubstring != "" {
+		vmeta.PortGroupSubstring = PortGroupNameSubstring
+	}
+	return vmeta
+}
+
+// tagLayout returns the tag layout of the flags
+func tagLayout() *vsphere.TagLayout {
+	layout, err := vsphere.NewTagLayout(RegionCategory, ZoneCategory, RegionTypes, ZoneTypes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &layout
+}
 
 func init() {
 	generateCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
 	generateCmd.Flags().StringVarP(&IBMCloudAuthFileName, "ibmcloud", "i", "ibmcloud.json", "vCenter JSON Auth File")
 	generateCmd.Flags().StringVarP(&ManifestDir, "manifests", "m", "./manifests", "Manifests output path")
+	generateCmd.Flags().StringVar(&ManifestFormat, "format", generation.FormatYAML, "Manifest format: "+strings.Join(generation.ManifestFormats, ", "))
 	generateCmd.Flags().StringVarP(&IPv6Subnet, "subnet6", "6", "fd65:a1a8:60ad", "IPv6 Subnet defaults to fd65:a1a8:60ad")
+	generateCmd.Flags().StringSliceVar(&IPv6Strategies, "ipv6-strategies", generation.DefaultIPv6Strategies, "IPv6 strategies tried in order: tag, vlan, ula and static")
+	generateCmd.Flags().StringVar(&IPv6StaticFileName, "ipv6-static-file", "", "IPv6 static mapping file used by the static strategy")
 	generateCmd.Flags().StringVarP(&PortGroupNameSubstring, "pg", "p", "ci-vlan-", "Port Group substring defaults to ci-vlan-")
+	generateCmd.Flags().IntVarP(&IPAddressCount, "ip-count", "c", 20, "Number of usable IP addresses per Network, 0 includes the whole range")
+	generateCmd.Flags().IntVar(&VIPPairs, "vip-pairs", 1, "Number of API and Ingress VIP pairs reserved per Network")
+	generateCmd.Flags().StringSliceVar(&SubnetTypes, "subnet-types", generation.DefaultSubnetTypes, "IBM subnet types of a VLAN that produce a Network")
+	generateCmd.Flags().IntVar(&PageSize, "page-size", 100, "Number of objects retrieved per IBM Cloud API request")
+	generateCmd.Flags().StringVar(&CacheDir, "cache-dir", "", "Optional directory caching IBM Cloud and vCenter lookups between runs")
+	generateCmd.Flags().StringToStringVar(&CacheTTLs, "cache-ttl", nil, "Cache TTL per kind, e.g. ibm-vlans=12h,vsphere-tags=30m")
+	generateCmd.Flags().BoolVar(&Refresh, "refresh", false, "Ignore cached lookups and refresh the cache")
+	generateCmd.Flags().StringVar(&ReportFileName, "report", "", "Optional path of the JSON run report")
+	generateCmd.Flags().StringSliceVar(&LocationStrategies, "location-strategies", generation.DefaultLocationStrategies, "vCenter location strategies tried in order: override, dns, guest, vpxd and baremetal")
+	generateCmd.Flags().StringVar(&LocationOverridesFileName, "location-overrides", "", "Optional file of explicit vCenter IBM datacenter and pod locations")
+	generateCmd.Flags().StringVar(&CostReportPrefix, "cost-report", "", "Optional path prefix of the monthly cost report, written as <prefix>.csv and <prefix>.json")
+	generateCmd.Flags().BoolVar(&HostInventory, "host-inventory", false, "Include the ESXi host and IBM bare metal inventory of each Pool in the run report")
+	generateCmd.Flags().StringVarP(&ReservationsFileName, "reservations", "r", "", "Optional file of reserved IP addresses and CIDRs, one per line")
+
+	addTagLayoutFlags(generateCmd)
+	addSessionFlags(generateCmd)
+	addCredentialFlags(generateCmd)
 
 	rootCmd.AddCommand(generateCmd)
 }
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+var ibmCmd = &cobra.Command{
+	Use:   "ibm",
+	Short: "Manage IBM Cloud SoftLayer resources",
+}
+
+var tagSubnetsCmd = &cobra.Command{
+	Use:   "tag-subnets",
+	Short: "Tag the IPv6 subnet of every CI VLAN with vcm:vlan=<vlan>",
+	Run: func(cmd *cobra.Command, args []string) {
+		imeta, accounts, err := generation.NewIBMCloudMetadata(generation.Options{
+			IBMCloudAuthFileName: IBMCloudAuthFileName,
+			Credentials:          credentialChain(),
+			Settings:             configSettings(),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var p plan.Plan
+		for _, account := range accounts {
+			if err := imeta.PlanSubnetTags(&p, account, Datacenter, VlanNameSubstring); err != nil {
+				log.Fatalf("unable to plan the subnet tags of account %s: %v", account, err)
+			}
+		}
+
+		if err := p.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		if DryRun || p.Empty() {
+			return
+		}
+		if err := p.Apply(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var orderSubnetsCmd = &cobra.Command{
+	Use:   "order-subnets",
+	Short: "Order a portable subnet for every CI VLAN without a usable subnet",
+	Long: `Order a portable subnet for every CI VLAN without a usable subnet.
+
+Each order is checked with verifyOrder and shown with its price. Orders are only
+placed, and billed, with both --place-order and --confirm.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if PlaceOrder != Confirm {
+			log.Fatal("placing orders requires both --place-order and --confirm")
+		}
+
+		opts := generation.Options{
+			VCenterAuthFileName:       VCenterAuthFileName,
+			IBMCloudAuthFileName:      IBMCloudAuthFileName,
+			Credentials:               credentialChain(),
+			Settings:                  configSettings(),
+			PortGroupNameSubstring:    PortGroupNameSubstring,
+			SubnetTypes:               SubnetTypes,
+			LocationStrategies:        LocationStrategies,
+			LocationOverridesFileName: LocationOverridesFileName,
+			ReservationsFileName:      ReservationsFileName,
+			VSphereSessionCacheDir:    VSphereSessionCacheDir,
+			VSphereKeepAlive:          VSphereKeepAlive,
+		}
+
+		missing, err := generation.FindVlansWithoutSubnets(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		imeta, _, err := generation.NewIBMCloudMetadata(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var p plan.Plan
+		for _, v := range missing {
+			order, err := imeta.NewPortableSubnetOrder(v.Account, v.VlanId, AddressSpace, SubnetSize)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			verified, err := imeta.VerifyOrder(v.Account, order)
+			if err != nil {
+				log.Fatalf("verifying the subnet order of vlan %d failed: %v", v.VlanNumber, err)
+			}
+
+			monthly := 0.0
+			if verified.PostTaxRecurring != nil {
+				monthly = float64(*verified.PostTaxRecurring)
+			}
+
+			portGroups := make([]string, 0, len(v.PortGroups))
+			for _, pg := range v.PortGroups {
+				portGroups = append(portGroups, fmt.Sprintf("%s on %s", pg.PortGroup, pg.Server))
+			}
+
+			p.Add(fmt.Sprintf("account %s %s %s vlan %d (port groups %s): order %d portable %s addresses for %.2f monthly", v.Account, v.Datacenter, v.Pod, v.VlanNumber, strings.Join(portGroups, ", "), SubnetSize, AddressSpace, monthly), func() error {
+				receipt, err := imeta.PlaceOrder(v.Account, order)
+				if err != nil {
+					return err
+				}
+				if receipt.OrderId != nil {
+					log.Printf("placed order %d for vlan %d", *receipt.OrderId, v.VlanNumber)
+				}
+				return nil
+			})
+		}
+
+		if err := p.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		if !PlaceOrder || p.Empty() {
+			return
+		}
+		if err := p.Apply(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var Datacenter string
+var VlanNameSubstring string
+var DryRun bool
+var SubnetSize int
+var AddressSpace string
+var PlaceOrder bool
+var Confirm bool
+
+func init() {
+	tagSubnetsCmd.Flags().StringVarP(&IBMCloudAuthFileName, "ibmcloud", "i", "ibmcloud.json", "IBM Cloud JSON Auth File")
+	tagSubnetsCmd.Flags().StringVar(&Datacenter, "datacenter", "", "Optional IBM datacenter, e.g. dal10, defaults to every datacenter")
+	tagSubnetsCmd.Flags().StringVar(&VlanNameSubstring, "vlan-name", "", "Substring of the names of the CI VLANs, defaults to every VLAN")
+	tagSubnetsCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Show the plan without applying it")
+	addCredentialFlags(tagSubnetsCmd)
+
+	orderSubnetsCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	orderSubnetsCmd.Flags().StringVarP(&IBMCloudAuthFileName, "ibmcloud", "i", "ibmcloud.json", "IBM Cloud JSON Auth File")
+	orderSubnetsCmd.Flags().StringVarP(&PortGroupNameSubstring, "pg", "p", "ci-vlan-", "Port Group substring defaults to ci-vlan-")
+	orderSubnetsCmd.Flags().StringSliceVar(&SubnetTypes, "subnet-types", generation.DefaultSubnetTypes, "IBM subnet types of a VLAN that produce a Network")
+	orderSubnetsCmd.Flags().StringSliceVar(&LocationStrategies, "location-strategies", generation.DefaultLocationStrategies, "vCenter location strategies tried in order: override, dns, guest, vpxd and baremetal")
+	orderSubnetsCmd.Flags().StringVar(&LocationOverridesFileName, "location-overrides", "", "Optional file of explicit vCenter IBM datacenter and pod locations")
+	orderSubnetsCmd.Flags().StringVarP(&ReservationsFileName, "reservations", "r", "", "Optional file of reserved IP addresses and CIDRs, one per line")
+	orderSubnetsCmd.Flags().IntVar(&SubnetSize, "size", 64, "Number of addresses of each portable subnet")
+	orderSubnetsCmd.Flags().StringVar(&AddressSpace, "address-space", ibmcloud.AddressSpacePrivate, "Address space of the portable subnets, private or public")
+	orderSubnetsCmd.Flags().BoolVar(&PlaceOrder, "place-order", false, "Place the verified orders, requires --confirm")
+	orderSubnetsCmd.Flags().BoolVar(&Confirm, "confirm", false, "Confirm the orders are placed and billed, requires --place-order")
+	addSessionFlags(orderSubnetsCmd)
+	addCredentialFlags(orderSubnetsCmd)
+
+	ibmCmd.AddCommand(tagSubnetsCmd)
+	ibmCmd.AddCommand(orderSubnetsCmd)
+	rootCmd.AddCommand(ibmCmd)
+}
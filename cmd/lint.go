@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the region and zone tags of every vCenter, exits non-zero on a violation",
+	Run: func(cmd *cobra.Command, args []string) {
+		vcenterCredentials, err := vsphereCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		servers := make([]string, 0, len(vcenterCredentials))
+		for server := range vcenterCredentials {
+			servers = append(servers, server)
+		}
+		sort.Strings(servers)
+
+		vmeta := newVSphereMetadata()
+		violations, err := lintTags(vmeta, servers, vcenterCredentials)
+		vmeta.Logout()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch OutputFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(violations)
+		default:
+			err = vsphere.WriteTagViolations(os.Stdout, violations)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(violations) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// lintTags returns the tag violations of every server
+func lintTags(vmeta *vsphere.Metadata, servers []string, vcenterCredentials map[string]vsphere.VCenterCredential) ([]vsphere.TagViolation, error) {
+	violations := make([]vsphere.TagViolation, 0)
+	for _, server := range servers {
+		if _, err := vmeta.AddVCenterCredential(server, vcenterCredentials[server]); err != nil {
+			return nil, err
+		}
+
+		serverViolations, err := vmeta.LintTags(server)
+		if err != nil {
+			return nil, fmt.Errorf("unable to lint %s: %w", server, err)
+		}
+		violations = append(violations, serverViolations...)
+	}
+	return violations, nil
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	lintCmd.Flags().StringVarP(&OutputFormat, "output", "o", "table", "Output format, table or json")
+
+	addTagLayoutFlags(lintCmd)
+	addSessionFlags(lintCmd)
+	addCredentialFlags(lintCmd)
+
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+)
+
+var explainPolicyCmd = &cobra.Command{
+	Use:   "explain-policy POOL",
+	Short: "Show which policy rule applies to a generated Pool and why the others do not",
+	Long: `Show which policy rule applies to a generated Pool and why the others do not.
+
+The Pool is read from the manifests directory and evaluated against the policy of the
+configuration file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pools, _, err := generation.ReadManifests(ManifestDir)
+		if err != nil {
+			log.Fatalf("unable to read manifests: %v", err)
+		}
+
+		name := strings.ToLower(args[0])
+		idx := -1
+		for i := range pools {
+			if pools[i].Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			log.Fatalf("no Pool %s in %s", name, ManifestDir)
+		}
+		pool := pools[idx]
+		fd := pool.Spec.VSpherePlatformFailureDomainSpec
+
+		s := configSettings()
+		resolution := s.Policy.Resolution
+		if resolution == "" {
+			resolution = generation.PolicyResolutionFirst
+		}
+
+		fmt.Printf("Pool %s\n", pool.Name)
+		fmt.Printf("  vCenter %s, datacenter %s, cluster %s, region %s, zone %s\n", fd.Server, fd.Topology.Datacenter, fd.Topology.ComputeCluster, fd.Region, fd.Zone)
+		fmt.Printf("Resolution %s\n", resolution)
+
+		evaluations, applied := s.Policy.Evaluate(&pool)
+		for i, e := range evaluations {
+			switch {
+			case i == applied:
+				fmt.Printf("  * %s: applied, %d criteria matched\n", e.Rule, e.Specificity)
+			case e.Matched:
+				fmt.Printf("    %s: matched, %d criteria, not applied\n", e.Rule, e.Specificity)
+			default:
+				fmt.Printf("    %s: %s\n", e.Rule, e.Reason)
+			}
+		}
+
+		if applied < 0 {
+			fmt.Printf("No rule matched, the capacity model applies: exclude %t, noSchedule %t\n", s.Capacity.Exclude, s.Capacity.NoSchedule)
+			return
+		}
+
+		r := s.Policy.Rules[applied]
+		exclude, noSchedule := s.Capacity.Exclude, s.Capacity.NoSchedule
+		if r.Exclude != nil {
+			exclude = *r.Exclude
+		}
+		if r.NoSchedule != nil {
+			noSchedule = *r.NoSchedule
+		}
+		fmt.Printf("Rule %s sets exclude %t, noSchedule %t\n", r.Name, exclude, noSchedule)
+		for _, kv := range sortedPairs(r.Labels) {
+			fmt.Printf("  label %s\n", kv)
+		}
+		for _, kv := range sortedPairs(r.Annotations) {
+			fmt.Printf("  annotation %s\n", kv)
+		}
+	},
+}
+
+func sortedPairs(m map[string]string) []string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func init() {
+	explainPolicyCmd.Flags().StringVarP(&ManifestDir, "manifests", "m", "./manifests", "Manifests path of the generated Pools")
+
+	rootCmd.AddCommand(explainPolicyCmd)
+}
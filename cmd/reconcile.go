@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+)
+
+var reconcileNetworksCmd = &cobra.Command{
+	Use:   "reconcile-networks",
+	Short: "Report port groups and IBM VLANs that do not produce a Network",
+	Run: func(cmd *cobra.Command, args []string) {
+		findings, err := generation.ReconcileNetworks(generation.Options{
+			VCenterAuthFileName:       VCenterAuthFileName,
+			IBMCloudAuthFileName:      IBMCloudAuthFileName,
+			Credentials:               credentialChain(),
+			Settings:                  configSettings(),
+			PortGroupNameSubstring:    PortGroupNameSubstring,
+			LocationStrategies:        LocationStrategies,
+			LocationOverridesFileName: LocationOverridesFileName,
+			TagLayout:                 tagLayout(),
+			VSphereSessionCacheDir:    VSphereSessionCacheDir,
+			VSphereKeepAlive:          VSphereKeepAlive,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch OutputFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(findings)
+		default:
+			err = generation.WriteReconcileFindings(os.Stdout, findings)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	reconcileNetworksCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	reconcileNetworksCmd.Flags().StringVarP(&IBMCloudAuthFileName, "ibmcloud", "i", "ibmcloud.json", "IBM Cloud JSON Auth File")
+	reconcileNetworksCmd.Flags().StringVarP(&PortGroupNameSubstring, "pg", "p", "ci-vlan-", "Port Group substring defaults to ci-vlan-")
+	reconcileNetworksCmd.Flags().StringSliceVar(&LocationStrategies, "location-strategies", generation.DefaultLocationStrategies, "vCenter location strategies tried in order: override, dns, guest, vpxd and baremetal")
+	reconcileNetworksCmd.Flags().StringVar(&LocationOverridesFileName, "location-overrides", "", "Optional file of explicit vCenter IBM datacenter and pod locations")
+	reconcileNetworksCmd.Flags().StringVarP(&OutputFormat, "output", "o", "table", "Output format, table or json")
+
+	addTagLayoutFlags(reconcileNetworksCmd)
+	addSessionFlags(reconcileNetworksCmd)
+	addCredentialFlags(reconcileNetworksCmd)
+
+	rootCmd.AddCommand(reconcileNetworksCmd)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/credentials"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Encrypt, decrypt and edit the vCenter and IBM Cloud auth files",
+}
+
+var secretsEncryptCmd = &cobra.Command{
+	Use:   "encrypt FILE",
+	Short: "Encrypt a plaintext auth file, in place unless --output is set",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := SecretsOutput
+		if output == "" {
+			output = args[0]
+		}
+		if err := credentials.EncryptFile(args[0], output, keys()); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("encrypted %s to %s", args[0], output)
+		if output != args[0] {
+			log.Printf("WARNING: the plaintext %s is still on disk, remove it", args[0])
+		}
+	},
+}
+
+var secretsDecryptCmd = &cobra.Command{
+	Use:   "decrypt FILE",
+	Short: "Print the decrypted auth file on stdout, the plaintext is never written to a file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		plaintext, err := credentials.ReadFile(args[0], keys())
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(plaintext)
+		clear(plaintext)
+	},
+}
+
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit FILE",
+	Short: "Set or delete fields of an encrypted auth file without decrypting it to disk",
+	Long: `Set or delete fields of an encrypted auth file without decrypting it to disk.
+
+--set <entry>.<field> reads the value from the terminal without echo, or a line of stdin,
+e.g. --set vcenter-hostname.Password. --delete <entry> removes a vCenter or account.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(SecretsSet) == 0 && len(SecretsDelete) == 0 {
+			log.Fatal("nothing to edit, set --set or --delete")
+		}
+
+		// the values are read before the passphrase prompt so a piped stdin is consumed in order
+		values := make(map[string]string, len(SecretsSet))
+		stdin := bufio.NewReader(os.Stdin)
+		for _, set := range SecretsSet {
+			if strings.LastIndex(set, ".") < 1 {
+				log.Fatalf("--set %s must be <entry>.<field>", set)
+			}
+			value, err := readSecretValue(stdin, set)
+			if err != nil {
+				log.Fatal(err)
+			}
+			values[set] = value
+		}
+
+		err := credentials.EditFile(args[0], keys(), func(entries map[string]map[string]any) error {
+			for _, entry := range SecretsDelete {
+				if _, ok := entries[entry]; !ok {
+					return fmt.Errorf("%s has no entry %s", args[0], entry)
+				}
+				delete(entries, entry)
+				log.Printf("deleted %s", entry)
+			}
+			for _, set := range SecretsSet {
+				// hostnames hold dots, the field is after the last one
+				i := strings.LastIndex(set, ".")
+				entry, field := set[:i], set[i+1:]
+				if entries[entry] == nil {
+					entries[entry] = make(map[string]any)
+				}
+				entries[entry][field] = values[set]
+				log.Printf("set %s of %s", field, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var SecretsOutput string
+var SecretsSet []string
+var SecretsDelete []string
+var KeyFile string
+var PassphraseFile string
+
+// addKeyFlags registers the flags of the key file and passphrase of encrypted auth files
+func addKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&KeyFile, "key-file", "", "Optional file holding the 32 byte key of encrypted auth files, defaults to "+credentials.EnvKeyFile)
+	cmd.Flags().StringVar(&PassphraseFile, "passphrase-file", "", "Optional file holding the passphrase of encrypted auth files, defaults to "+credentials.EnvPassphrase+" or a prompt")
+}
+
+// keys returns the key file and passphrase of the flags
+func keys() credentials.Keys {
+	return credentials.Keys{KeyFile: KeyFile, PassphraseFile: PassphraseFile}
+}
+
+// readSecretValue prompts for the value without echo on a terminal, or reads a line of stdin
+func readSecretValue(stdin *bufio.Reader, name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "%s: ", name)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no value of %s on stdin", name)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func init() {
+	secretsEncryptCmd.Flags().StringVarP(&SecretsOutput, "output", "o", "", "Optional path of the encrypted file, defaults to replacing FILE")
+	secretsEditCmd.Flags().StringArrayVar(&SecretsSet, "set", nil, "Field to set, <entry>.<field>, the value is read from the terminal or stdin")
+	secretsEditCmd.Flags().StringArrayVar(&SecretsDelete, "delete", nil, "Entry to delete")
+
+	for _, c := range []*cobra.Command{secretsEncryptCmd, secretsDecryptCmd, secretsEditCmd} {
+		addKeyFlags(c)
+		secretsCmd.AddCommand(c)
+	}
+	rootCmd.AddCommand(secretsCmd)
+}
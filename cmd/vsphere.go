@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+var vsphereCmd = &cobra.Command{
+	Use:   "vsphere",
+	Short: "Manage vCenter resources",
+}
+
+var createPortGroupsCmd = &cobra.Command{
+	Use:   "create-portgroups",
+	Short: "Create a port group for every selected IBM VLAN in the vCenter's pod without one",
+	Run: func(cmd *cobra.Command, args []string) {
+		if SwitchName == "" {
+			log.Fatal("--switch is required")
+		}
+		selection := generation.VlanSelection{Numbers: VlanNumbers, NameSubstring: VlanNameSubstring, Tags: VlanTags}
+		if selection.Empty() {
+			log.Fatal("select the VLANs with --vlans, --vlan-name or --vlan-tag")
+		}
+
+		findings, err := generation.ReconcileNetworks(generation.Options{
+			VCenterAuthFileName:       VCenterAuthFileName,
+			IBMCloudAuthFileName:      IBMCloudAuthFileName,
+			Credentials:               credentialChain(),
+			Settings:                  configSettings(),
+			PortGroupNameSubstring:    PortGroupNameSubstring,
+			LocationStrategies:        LocationStrategies,
+			LocationOverridesFileName: LocationOverridesFileName,
+			TagLayout:                 tagLayout(),
+			VSphereSessionCacheDir:    VSphereSessionCacheDir,
+			VSphereKeepAlive:          VSphereKeepAlive,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		vlansByServer := make(map[string][]int32)
+		for _, f := range findings {
+			if f.Kind == generation.FindingVlanWithoutPortGroup && selection.Matches(f) {
+				vlansByServer[f.Server] = append(vlansByServer[f.Server], f.VlanId)
+			}
+		}
+
+		vcenterCredentials, err := vsphereCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		servers := make([]string, 0, len(vlansByServer))
+		for server := range vlansByServer {
+			servers = append(servers, server)
+		}
+		sort.Strings(servers)
+
+		err = applyPlan(newVSphereMetadata(), func(vmeta *vsphere.Metadata, p *plan.Plan) error {
+			for _, server := range servers {
+				if _, err := vmeta.AddVCenterCredential(server, vcenterCredentials[server]); err != nil {
+					return err
+				}
+				if err := vmeta.PlanPortGroups(p, server, SwitchName, PortGroupNameSubstring, vlansByServer[server], Uplinks); err != nil {
+					return fmt.Errorf("unable to plan the port groups of %s: %w", server, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var tagApplyCmd = &cobra.Command{
+	Use:   "tag-apply",
+	Short: "Create and attach the region and zone tags declared in a topology file",
+	Run: func(cmd *cobra.Command, args []string) {
+		topology, err := vsphere.ReadTopology(TopologyFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		vcenterCredentials, err := vsphereCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = applyPlan(newVSphereMetadata(), func(vmeta *vsphere.Metadata, p *plan.Plan) error {
+			for _, vc := range topology.VCenters {
+				v, ok := vcenterCredentials[vc.Server]
+				if !ok {
+					return fmt.Errorf("no credentials for vCenter %s", vc.Server)
+				}
+				if _, err := vmeta.AddVCenterCredential(vc.Server, v); err != nil {
+					return err
+				}
+				if err := vmeta.PlanTopologyTags(p, vc); err != nil {
+					return fmt.Errorf("unable to plan the tags of %s: %w", vc.Server, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var createDRSGroupsCmd = &cobra.Command{
+	Use:   "create-drs-groups",
+	Short: "Create the missing DRS host groups, VM groups and VM/Host rules of host group zones",
+	Run: func(cmd *cobra.Command, args []string) {
+		vcenterCredentials, err := vsphereCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		servers := make([]string, 0, len(vcenterCredentials))
+		for server := range vcenterCredentials {
+			servers = append(servers, server)
+		}
+		sort.Strings(servers)
+
+		layout := tagLayout()
+		if !slices.Contains(layout.ZoneTypes, vsphere.ZoneTypeHostGroup) {
+			log.Fatalf("--zone-types must include %s", vsphere.ZoneTypeHostGroup)
+		}
+
+		err = applyPlan(newVSphereMetadata(), func(vmeta *vsphere.Metadata, p *plan.Plan) error {
+			for _, server := range servers {
+				if _, err := vmeta.AddVCenterCredential(server, vcenterCredentials[server]); err != nil {
+					return err
+				}
+				if err := vmeta.PlanDRSGroups(p, server, Mandatory); err != nil {
+					return fmt.Errorf("unable to plan the DRS groups of %s: %w", server, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// applyPlan plans the changes with the vCenter sessions and applies them unless it is a dry run,
+// the sessions are logged out before it returns
+func applyPlan(vmeta *vsphere.Metadata, planChanges func(vmeta *vsphere.Metadata, p *plan.Plan) error) error {
+	defer vmeta.Logout()
+
+	var p plan.Plan
+	if err := planChanges(vmeta, &p); err != nil {
+		return err
+	}
+	if err := p.Write(os.Stdout); err != nil {
+		return err
+	}
+	if DryRun || p.Empty() {
+		return nil
+	}
+	return p.Apply()
+}
+
+var SwitchName string
+var Uplinks []string
+var VlanNumbers []int32
+var VlanTags []string
+var TopologyFileName string
+var Mandatory bool
+
+func init() {
+	createPortGroupsCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	createPortGroupsCmd.Flags().StringVarP(&IBMCloudAuthFileName, "ibmcloud", "i", "ibmcloud.json", "IBM Cloud JSON Auth File")
+	createPortGroupsCmd.Flags().StringVarP(&PortGroupNameSubstring, "pg", "p", "ci-vlan-", "Port Group name prefix, the VLAN id is appended")
+	createPortGroupsCmd.Flags().StringVar(&SwitchName, "switch", "", "Name of the distributed switch the port groups are created on")
+	createPortGroupsCmd.Flags().Int32SliceVar(&VlanNumbers, "vlans", nil, "Numbers of the IBM VLANs port groups are created for")
+	createPortGroupsCmd.Flags().StringVar(&VlanNameSubstring, "vlan-name", "", "Substring of the names of the IBM VLANs port groups are created for")
+	createPortGroupsCmd.Flags().StringSliceVar(&VlanTags, "vlan-tag", nil, "Tags of the IBM VLANs port groups are created for")
+	createPortGroupsCmd.Flags().StringSliceVar(&Uplinks, "uplinks", nil, "Physical nics, e.g. vmnic2,vmnic3, the hosts missing from the switch are added with, without them the hosts are only reported")
+	createPortGroupsCmd.Flags().StringSliceVar(&LocationStrategies, "location-strategies", generation.DefaultLocationStrategies, "vCenter location strategies tried in order: override, dns, guest, vpxd and baremetal")
+	createPortGroupsCmd.Flags().StringVar(&LocationOverridesFileName, "location-overrides", "", "Optional file of explicit vCenter IBM datacenter and pod locations")
+	createPortGroupsCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Show the plan without applying it")
+	addTagLayoutFlags(createPortGroupsCmd)
+	addSessionFlags(createPortGroupsCmd)
+	addCredentialFlags(createPortGroupsCmd)
+
+	tagApplyCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	tagApplyCmd.Flags().StringVarP(&TopologyFileName, "file", "f", "topology.yaml", "Topology file declaring the regions and zones of each vCenter")
+	tagApplyCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Show the plan without applying it")
+	tagApplyCmd.Flags().StringVar(&RegionCategory, "region-category", vsphere.DefaultTagLayout().RegionCategory, "Tag category of regions")
+	tagApplyCmd.Flags().StringVar(&ZoneCategory, "zone-category", vsphere.DefaultTagLayout().ZoneCategory, "Tag category of zones")
+	addSessionFlags(tagApplyCmd)
+	addCredentialFlags(tagApplyCmd)
+
+	createDRSGroupsCmd.Flags().StringVarP(&VCenterAuthFileName, "vcenter", "v", "vcenter.json", "vCenter JSON Auth File")
+	createDRSGroupsCmd.Flags().BoolVar(&Mandatory, "mandatory", false, "Create must run on rules instead of should run on rules")
+	createDRSGroupsCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Show the plan without applying it")
+	addTagLayoutFlags(createDRSGroupsCmd)
+	addSessionFlags(createDRSGroupsCmd)
+	addCredentialFlags(createDRSGroupsCmd)
+
+	vsphereCmd.AddCommand(createPortGroupsCmd)
+	vsphereCmd.AddCommand(createDRSGroupsCmd)
+	vsphereCmd.AddCommand(tagApplyCmd)
+	rootCmd.AddCommand(vsphereCmd)
+}
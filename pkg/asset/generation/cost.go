@@ -0,0 +1,177 @@
+package generation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+// monthlyCostAnnotation is the recurring monthly cost in USD attributed to a Pool or Network
+const monthlyCostAnnotation = "vspherecapacitymanager.splat.io/monthly-cost"
+
+const (
+	CostKindPool    = "Pool"
+	CostKindNetwork = "Network"
+
+	CostSourceHardware = "hardware"
+	CostSourceVlan     = "vlan"
+	CostSourceSubnet   = "subnet"
+)
+
+// CostSource a billed SoftLayer object and the part of its monthly fee attributed
+type CostSource struct {
+	Type string `json:"type"`
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+
+	// MonthlyFee is the full recurring fee of the object
+	MonthlyFee float64 `json:"monthlyFee"`
+
+	// MonthlyCost is the part of MonthlyFee attributed, the fee of a vlan is
+	// split evenly between its Networks.
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+// CostAttribution the recurring monthly cost of a Pool or Network
+type CostAttribution struct {
+	Kind        string       `json:"kind"`
+	Name        string       `json:"name"`
+	Datacenter  string       `json:"datacenter,omitempty"`
+	Pod         string       `json:"pod,omitempty"`
+	MonthlyCost float64      `json:"monthlyCost"`
+	Sources     []CostSource `json:"sources"`
+}
+
+func (c *CostAttribution) add(source CostSource) {
+	c.Sources = append(c.Sources, source)
+	c.MonthlyCost += source.MonthlyCost
+}
+
+func (c *CostAttribution) annotate(annotations map[string]string) {
+	annotations[monthlyCostAnnotation] = strconv.FormatFloat(c.MonthlyCost, 'f', 2, 64)
+}
+
+// newPoolCost attributes the fees of the bare metal servers backing the hosts of a Pool
+func newPoolCost(name string, location *ibmcloud.VCenterLocation, hosts []vsphere.Host, hardware []datatypes.Hardware) CostAttribution {
+	cost := CostAttribution{
+		Kind: CostKindPool,
+		Name: name,
+	}
+	if location.DatacenterName != nil {
+		cost.Datacenter = *location.DatacenterName
+		cost.Pod = *location.PodName
+	}
+
+	seen := make(map[int]bool)
+	for _, h := range hosts {
+		hw := ibmcloud.FindHardware(hardware, h.ManagementIPs, h.Name)
+		if hw == nil || hw.Id == nil || seen[*hw.Id] {
+			continue
+		}
+		seen[*hw.Id] = true
+
+		fee := ibmcloud.HardwareMonthlyFee(*hw)
+		cost.add(CostSource{
+			Type:        CostSourceHardware,
+			Id:          *hw.Id,
+			Name:        newBareMetalInventory(*hw).Hostname,
+			MonthlyFee:  fee,
+			MonthlyCost: fee,
+		})
+	}
+
+	return cost
+}
+
+// newNetworkCost attributes a share of the vlan fee and the fee of the subnet to a
+// Network. vlanShares is the number of Networks created on the vlan.
+func newNetworkCost(name string, nv datatypes.Network_Vlan, subnet datatypes.Network_Subnet, vlanShares int) CostAttribution {
+	cost := CostAttribution{
+		Kind: CostKindNetwork,
+		Name: name,
+	}
+	if nv.Datacenter != nil && nv.Datacenter.Name != nil {
+		cost.Datacenter = *nv.Datacenter.Name
+	}
+	if nv.PodName != nil {
+		cost.Pod = *nv.PodName
+	}
+
+	if nv.Id != nil {
+		fee := ibmcloud.MonthlyFee(nv.BillingItem)
+		vlanName := ""
+		if nv.VlanNumber != nil {
+			vlanName = strconv.Itoa(*nv.VlanNumber)
+		}
+		cost.add(CostSource{
+			Type:        CostSourceVlan,
+			Id:          *nv.Id,
+			Name:        vlanName,
+			MonthlyFee:  fee,
+			MonthlyCost: fee / float64(max(vlanShares, 1)),
+		})
+	}
+
+	if subnet.Id != nil {
+		fee := ibmcloud.MonthlyFee(subnet.BillingItem)
+		subnetName := ""
+		if subnet.NetworkIdentifier != nil && subnet.Cidr != nil {
+			subnetName = fmt.Sprintf("%s/%d", *subnet.NetworkIdentifier, *subnet.Cidr)
+		}
+		cost.add(CostSource{
+			Type:        CostSourceSubnet,
+			Id:          *subnet.Id,
+			Name:        subnetName,
+			MonthlyFee:  fee,
+			MonthlyCost: fee,
+		})
+	}
+
+	return cost
+}
+
+// WriteCostReport writes the costs to <prefix>.json and, one row per attributed
+// source, to <prefix>.csv
+func WriteCostReport(costs []CostAttribution, prefix string) error {
+	marshalled, err := json.MarshalIndent(costs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(prefix+".json", marshalled, 0644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"kind", "name", "datacenter", "pod", "monthly_cost", "source_type", "source_id", "source_name", "source_monthly_fee", "source_monthly_cost"}); err != nil {
+		return err
+	}
+
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	for _, c := range costs {
+		for _, s := range c.Sources {
+			err := w.Write([]string{c.Kind, c.Name, c.Datacenter, c.Pod, money(c.MonthlyCost), s.Type, strconv.Itoa(s.Id), s.Name, money(s.MonthlyFee), money(s.MonthlyCost)})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
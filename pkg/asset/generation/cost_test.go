@@ -0,0 +1,198 @@
+package generation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/sl"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/internal/ibmcloudtest"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const fakeAccount = "fake-account"
+
+var fakeResponses = map[string]string{
+	"SoftLayer_Account::getNetworkVlans": `[{
+		"id": 100,
+		"vlanNumber": 1234,
+		"podName": "dal10.pod01",
+		"datacenter": {"name": "dal10"},
+		"billingItem": {"id": 1000, "recurringFee": "30.00"},
+		"subnets": [
+			{"id": 200, "networkIdentifier": "10.0.0.0", "cidr": 26, "billingItem": {"id": 2000, "recurringFee": "5.00", "nextInvoiceTotalRecurringAmount": 6}},
+			{"id": 201, "networkIdentifier": "10.0.0.64", "cidr": 26},
+			{"id": 202, "networkIdentifier": "10.0.0.128", "cidr": 26, "billingItem": {"id": 2002, "recurringFee": "4.50"}}
+		]
+	}]`,
+	"SoftLayer_Account::getHardware": `[
+		{"id": 300, "hostname": "host1", "fullyQualifiedDomainName": "host1.example.com", "primaryBackendIpAddress": "10.1.0.1", "billingItem": {"id": 3000, "recurringFee": "1000.00"}},
+		{"id": 301, "hostname": "host2", "fullyQualifiedDomainName": "host2.example.com", "billingItem": {"id": 3001, "recurringFee": "1500.50"}},
+		{"id": 302, "hostname": "host3", "fullyQualifiedDomainName": "host3.example.com", "billingItem": {"id": 3002, "recurringFee": "900.00"}}
+	]`,
+}
+
+func newFakeIBMCloudMetadata(t *testing.T) *ibmcloud.Metadata {
+	t.Helper()
+	m := ibmcloud.NewMetadata()
+	m.Transport = ibmcloudtest.NewFakeTransport(fakeResponses)
+	if err := m.AddCredentials(fakeAccount, "user", "token"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestNewNetworkCost(t *testing.T) {
+	vlans, err := newFakeIBMCloudMetadata(t).GetVlanSubnets(fakeAccount, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*vlans) != 1 {
+		t.Fatalf("expected 1 vlan, got %d", len(*vlans))
+	}
+	nv := (*vlans)[0]
+
+	expected := []struct {
+		monthlyCost float64
+		sources     []CostSource
+	}{
+		{16, []CostSource{
+			{Type: CostSourceVlan, Id: 100, Name: "1234", MonthlyFee: 30, MonthlyCost: 10},
+			{Type: CostSourceSubnet, Id: 200, Name: "10.0.0.0/26", MonthlyFee: 6, MonthlyCost: 6},
+		}},
+		{10, []CostSource{
+			{Type: CostSourceVlan, Id: 100, Name: "1234", MonthlyFee: 30, MonthlyCost: 10},
+			{Type: CostSourceSubnet, Id: 201, Name: "10.0.0.64/26", MonthlyFee: 0, MonthlyCost: 0},
+		}},
+		{14.5, []CostSource{
+			{Type: CostSourceVlan, Id: 100, Name: "1234", MonthlyFee: 30, MonthlyCost: 10},
+			{Type: CostSourceSubnet, Id: 202, Name: "10.0.0.128/26", MonthlyFee: 4.5, MonthlyCost: 4.5},
+		}},
+	}
+
+	var total float64
+	for i, subnet := range nv.Subnets {
+		cost := newNetworkCost("network", nv, subnet, len(nv.Subnets))
+		if cost.Kind != CostKindNetwork || cost.Datacenter != "dal10" || cost.Pod != "dal10.pod01" {
+			t.Errorf("subnet %d: unexpected kind or location %s %s %s", i, cost.Kind, cost.Datacenter, cost.Pod)
+		}
+		if cost.MonthlyCost != expected[i].monthlyCost {
+			t.Errorf("subnet %d: expected monthly cost %v, got %v", i, expected[i].monthlyCost, cost.MonthlyCost)
+		}
+		if !reflect.DeepEqual(cost.Sources, expected[i].sources) {
+			t.Errorf("subnet %d: expected sources %+v, got %+v", i, expected[i].sources, cost.Sources)
+		}
+		total += cost.MonthlyCost
+	}
+
+	// the vlan fee is charged once in total, the subnet fees once each
+	if total != 30+6+4.5 {
+		t.Errorf("expected a total of %v, got %v", 30+6+4.5, total)
+	}
+}
+
+func TestNewPoolCost(t *testing.T) {
+	hardware, err := newFakeIBMCloudMetadata(t).GetHardware(fakeAccount, "dal10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location := &ibmcloud.VCenterLocation{DatacenterName: sl.String("dal10"), PodName: sl.String("dal10.pod01")}
+	hosts := []vsphere.Host{
+		// matched by management address
+		{Name: "esxi-a", ManagementIPs: []net.IP{net.ParseIP("10.1.0.1")}},
+		// matched by hostname
+		{Name: "host2.example.com"},
+		// the same server again is charged once
+		{Name: "host2"},
+		// no server
+		{Name: "unknown.example.com"},
+	}
+
+	cost := newPoolCost("pool", location, hosts, hardware)
+	if cost.Kind != CostKindPool || cost.Datacenter != "dal10" || cost.Pod != "dal10.pod01" {
+		t.Errorf("unexpected kind or location %s %s %s", cost.Kind, cost.Datacenter, cost.Pod)
+	}
+	expected := []CostSource{
+		{Type: CostSourceHardware, Id: 300, Name: "host1.example.com", MonthlyFee: 1000, MonthlyCost: 1000},
+		{Type: CostSourceHardware, Id: 301, Name: "host2.example.com", MonthlyFee: 1500.5, MonthlyCost: 1500.5},
+	}
+	if !reflect.DeepEqual(cost.Sources, expected) {
+		t.Errorf("expected sources %+v, got %+v", expected, cost.Sources)
+	}
+	if cost.MonthlyCost != 2500.5 {
+		t.Errorf("expected monthly cost 2500.5, got %v", cost.MonthlyCost)
+	}
+
+	annotations := make(map[string]string)
+	cost.annotate(annotations)
+	if annotations[monthlyCostAnnotation] != "2500.50" {
+		t.Errorf("expected annotation 2500.50, got %s", annotations[monthlyCostAnnotation])
+	}
+
+	unlocated := newPoolCost("pool", &ibmcloud.VCenterLocation{}, hosts, []datatypes.Hardware{})
+	if unlocated.Datacenter != "" || len(unlocated.Sources) != 0 || unlocated.MonthlyCost != 0 {
+		t.Errorf("expected no cost without hardware, got %+v", unlocated)
+	}
+}
+
+func TestWriteCostReport(t *testing.T) {
+	costs := []CostAttribution{
+		{
+			Kind: CostKindPool, Name: "pool-1", Datacenter: "dal10", Pod: "dal10.pod01", MonthlyCost: 2500.5,
+			Sources: []CostSource{
+				{Type: CostSourceHardware, Id: 300, Name: "host1.example.com", MonthlyFee: 1000, MonthlyCost: 1000},
+				{Type: CostSourceHardware, Id: 301, Name: "host2.example.com", MonthlyFee: 1500.5, MonthlyCost: 1500.5},
+			},
+		},
+		{
+			Kind: CostKindNetwork, Name: "network-1", Datacenter: "dal10", Pod: "dal10.pod01", MonthlyCost: 1.0 / 3,
+			Sources: []CostSource{
+				{Type: CostSourceVlan, Id: 100, Name: "1234", MonthlyFee: 1, MonthlyCost: 1.0 / 3},
+			},
+		},
+	}
+
+	prefix := filepath.Join(t.TempDir(), "costs")
+	if err := WriteCostReport(costs, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read []CostAttribution
+	if err := json.Unmarshal(b, &read); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, costs) {
+		t.Errorf("expected json %+v, got %+v", costs, read)
+	}
+
+	f, err := os.Open(prefix + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"kind", "name", "datacenter", "pod", "monthly_cost", "source_type", "source_id", "source_name", "source_monthly_fee", "source_monthly_cost"},
+		{"Pool", "pool-1", "dal10", "dal10.pod01", "2500.50", "hardware", "300", "host1.example.com", "1000.00", "1000.00"},
+		{"Pool", "pool-1", "dal10", "dal10.pod01", "2500.50", "hardware", "301", "host2.example.com", "1500.50", "1500.50"},
+		{"Network", "network-1", "dal10", "dal10.pod01", "0.33", "vlan", "100", "1234", "1.00", "0.33"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected csv %v, got %v", expected, rows)
+	}
+}
@@ -0,0 +1,44 @@
+package generation
+
+import (
+	"github.com/vmware/govmomi/object"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	hostGroupAnnotation = "vspherecapacitymanager.splat.io/host-group"
+	vmGroupAnnotation   = "vspherecapacitymanager.splat.io/vm-group"
+)
+
+// hostGroupHosts returns the hosts of the cluster in the host group zone
+func hostGroupHosts(vmeta *vsphere.Metadata, cluster *object.ClusterComputeResource, hgz vsphere.HostGroupZone) ([]vsphere.Host, error) {
+	clusterHosts, err := vmeta.GetClusterHosts(hgz.Server, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool, len(hgz.Hosts))
+	for _, h := range hgz.Hosts {
+		members[h] = true
+	}
+
+	var hosts []vsphere.Host
+	for _, h := range clusterHosts {
+		if members[h.InventoryPath] {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+// hostsCapacity sums the cpu cores and memory of the hosts like the cluster summary does
+func hostsCapacity(hosts []vsphere.Host) (int16, int64) {
+	var cpu int16
+	var memory int64
+	for _, h := range hosts {
+		cpu += h.NumCpuCores
+		memory += h.MemorySize
+	}
+	return cpu, memory
+}
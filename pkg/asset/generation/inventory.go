@@ -0,0 +1,123 @@
+package generation
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+// BareMetalInventory the IBM bare metal server backing an ESXi host
+type BareMetalInventory struct {
+	Id            int      `json:"id"`
+	Hostname      string   `json:"hostname"`
+	Datacenter    string   `json:"datacenter"`
+	Pod           string   `json:"pod"`
+	Rack          string   `json:"rack,omitempty"`
+	ServerRoom    string   `json:"serverRoom,omitempty"`
+	CpuModel      string   `json:"cpuModel,omitempty"`
+	PhysicalCores uint     `json:"physicalCores"`
+	MemoryGB      uint     `json:"memoryGB"`
+	NicSpeeds     []string `json:"nicSpeeds,omitempty"`
+}
+
+// HostInventory an ESXi host and the bare metal server backing it, BareMetal
+// is nil if no server could be matched.
+type HostInventory struct {
+	vsphere.Host
+	BareMetal *BareMetalInventory `json:"bareMetal,omitempty"`
+}
+
+// PoolHostInventory the hosts of a Pool
+type PoolHostInventory struct {
+	Server     string          `json:"server"`
+	Datacenter string          `json:"datacenter"`
+	Pod        string          `json:"pod"`
+	Hosts      []HostInventory `json:"hosts"`
+	Warnings   []string        `json:"warnings,omitempty"`
+}
+
+func newBareMetalInventory(hw datatypes.Hardware) *BareMetalInventory {
+	bm := &BareMetalInventory{
+		Pod: ibmcloud.HardwarePod(hw),
+	}
+
+	if hw.Id != nil {
+		bm.Id = *hw.Id
+	}
+	if hw.FullyQualifiedDomainName != nil {
+		bm.Hostname = *hw.FullyQualifiedDomainName
+	} else if hw.Hostname != nil {
+		bm.Hostname = *hw.Hostname
+	}
+	if hw.Datacenter != nil && hw.Datacenter.Name != nil {
+		bm.Datacenter = *hw.Datacenter.Name
+	}
+	if hw.Rack != nil && hw.Rack.Name != nil {
+		bm.Rack = *hw.Rack.Name
+	}
+	if hw.ServerRoom != nil && hw.ServerRoom.LongName != nil {
+		bm.ServerRoom = *hw.ServerRoom.LongName
+	}
+	for _, p := range hw.Processors {
+		if p.HardwareComponentModel != nil && p.HardwareComponentModel.LongDescription != nil {
+			bm.CpuModel = *p.HardwareComponentModel.LongDescription
+			break
+		}
+	}
+	if hw.ProcessorPhysicalCoreAmount != nil {
+		bm.PhysicalCores = *hw.ProcessorPhysicalCoreAmount
+	}
+	if hw.MemoryCapacity != nil {
+		bm.MemoryGB = *hw.MemoryCapacity
+	}
+	for _, nc := range hw.NetworkComponents {
+		if nc.Name == nil || nc.Speed == nil {
+			continue
+		}
+		port := 0
+		if nc.Port != nil {
+			port = *nc.Port
+		}
+		bm.NicSpeeds = append(bm.NicSpeeds, fmt.Sprintf("%s%d %dMbps", *nc.Name, port, *nc.Speed))
+	}
+
+	return bm
+}
+
+// newPoolHostInventory joins the hosts of a Pool with the bare metal servers by management
+// address or hostname and warns about hosts that are not in the pod of the Pool's vlans.
+func newPoolHostInventory(server string, location *ibmcloud.VCenterLocation, hosts []vsphere.Host, hardware []datatypes.Hardware) PoolHostInventory {
+	inventory := PoolHostInventory{
+		Server: server,
+	}
+	if location.DatacenterName != nil {
+		inventory.Datacenter = *location.DatacenterName
+		inventory.Pod = *location.PodName
+	}
+
+	for _, h := range hosts {
+		hi := HostInventory{Host: h}
+
+		if hw := ibmcloud.FindHardware(hardware, h.ManagementIPs, h.Name); hw != nil {
+			hi.BareMetal = newBareMetalInventory(*hw)
+
+			if inventory.Pod != "" && hi.BareMetal.Pod != "" && hi.BareMetal.Pod != inventory.Pod {
+				inventory.Warnings = append(inventory.Warnings, fmt.Sprintf("host %s is backed by %s in pod %s, the Pool vlans are in pod %s", h.InventoryPath, hi.BareMetal.Hostname, hi.BareMetal.Pod, inventory.Pod))
+			}
+		} else {
+			inventory.Warnings = append(inventory.Warnings, fmt.Sprintf("host %s does not match an IBM bare metal server", h.InventoryPath))
+		}
+
+		inventory.Hosts = append(inventory.Hosts, hi)
+	}
+
+	for _, w := range inventory.Warnings {
+		log.Printf("WARNING: %s", w)
+	}
+
+	return inventory
+}
@@ -0,0 +1,230 @@
+package generation
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/c-robinson/iplib/v2"
+	"github.com/softlayer/softlayer-go/datatypes"
+	"sigs.k8s.io/yaml"
+)
+
+const ipv6StrategyAnnotation = "vspherecapacitymanager.splat.io/ipv6-strategy"
+
+// IPv6Strategy a method of determining the IPv6 subnet of a Network
+type IPv6Strategy string
+
+const (
+	// IPv6StrategyTag uses the IBM IPv6 subnet tagged for the vlan
+	IPv6StrategyTag IPv6Strategy = "tag"
+	// IPv6StrategyVlan uses the IBM IPv6 subnet attached to the vlan
+	IPv6StrategyVlan IPv6Strategy = "vlan"
+	// IPv6StrategyULA derives a unique local /64 from the ULA prefix and the vlan id
+	IPv6StrategyULA IPv6Strategy = "ula"
+	// IPv6StrategyStatic uses the subnet of the vlan in the static mapping file
+	IPv6StrategyStatic IPv6Strategy = "static"
+)
+
+// DefaultIPv6Strategies are tried in order unless configured otherwise
+var DefaultIPv6Strategies = []string{string(IPv6StrategyTag), string(IPv6StrategyVlan), string(IPv6StrategyULA)}
+
+// StaticIPv6Subnet an entry of the IPv6 static mapping file
+type StaticIPv6Subnet struct {
+	// Datacenter IBM datacenter name of the vlan, e.g. dal10
+	Datacenter string `json:"datacenter"`
+	// Pod optional IBM pod name of the vlan, e.g. dal10.pod01
+	Pod string `json:"pod,omitempty"`
+	// Vlan the vlan number
+	Vlan int `json:"vlan"`
+	// Prefix IPv6 CIDR of the subnet
+	Prefix string `json:"prefix"`
+	// Gateway optional gateway, defaults to the second address after the network address
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// ReadStaticIPv6Subnets parses the IPv6 static mapping file
+func ReadStaticIPv6Subnets(fileName string) ([]StaticIPv6Subnet, error) {
+	var static []StaticIPv6Subnet
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(b, &static); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+	return static, nil
+}
+
+// ipv6Subnet the IPv6 addressing of a Network
+type ipv6Subnet struct {
+	Prefix   iplib.Net6
+	Gateway  net.IP
+	Start    net.IP
+	Strategy IPv6Strategy
+}
+
+type allocatedIPv6 struct {
+	prefix iplib.Net6
+	vlan   string
+}
+
+// ipv6Allocator determines the IPv6 subnet of each vlan using the configured
+// strategies in order and keeps track of the prefixes handed out so far.
+type ipv6Allocator struct {
+	strategies []IPv6Strategy
+	ulaPrefix  string
+	static     []StaticIPv6Subnet
+	allocated  []allocatedIPv6
+
+	// byVlan the subnet of each vlan allocated so far, nil if no strategy produced one
+	byVlan map[string]*ipv6Subnet
+}
+
+func newIPv6Allocator(opts Options) (*ipv6Allocator, error) {
+	a := &ipv6Allocator{
+		ulaPrefix: opts.IPv6Subnet,
+		byVlan:    make(map[string]*ipv6Subnet),
+	}
+
+	strategies := opts.IPv6Strategies
+	if len(strategies) == 0 {
+		strategies = DefaultIPv6Strategies
+	}
+
+	for _, s := range strategies {
+		switch strategy := IPv6Strategy(s); strategy {
+		case IPv6StrategyTag, IPv6StrategyVlan, IPv6StrategyULA:
+			a.strategies = append(a.strategies, strategy)
+		case IPv6StrategyStatic:
+			if opts.IPv6StaticFileName == "" {
+				return nil, fmt.Errorf("the %s IPv6 strategy requires a static mapping file", strategy)
+			}
+			static, err := ReadStaticIPv6Subnets(opts.IPv6StaticFileName)
+			if err != nil {
+				return nil, err
+			}
+			a.static = static
+			a.strategies = append(a.strategies, strategy)
+		default:
+			return nil, fmt.Errorf("unknown IPv6 strategy %s", s)
+		}
+	}
+
+	return a, nil
+}
+
+// allocate returns the IPv6 subnet of the vlan from the first strategy that yields a
+// prefix not overlapping an allocated prefix, nil if no strategy does. A vlan allocated
+// again, e.g. seen from another vCenter of its pod, gets the same subnet.
+func (a *ipv6Allocator) allocate(nv datatypes.Network_Vlan, taggedSubnets []datatypes.Network_Subnet) *ipv6Subnet {
+	vlanKey := fmt.Sprintf("%s/%s/%d", *nv.Datacenter.Name, *nv.PodName, *nv.VlanNumber)
+	if subnet, ok := a.byVlan[vlanKey]; ok {
+		return subnet
+	}
+
+	var subnet *ipv6Subnet
+	for _, strategy := range a.strategies {
+		prefix, gateway := a.candidate(strategy, nv, taggedSubnets)
+		if prefix.IP() == nil {
+			continue
+		}
+
+		if other := a.overlaps(prefix); other != "" {
+			log.Printf("WARNING: IPv6 %s prefix %s of vlan %s overlaps vlan %s, trying next strategy", strategy, prefix.String(), vlanKey, other)
+			continue
+		}
+
+		a.allocated = append(a.allocated, allocatedIPv6{prefix: prefix, vlan: vlanKey})
+		subnet = newIPv6Subnet(prefix, gateway, strategy)
+		break
+	}
+
+	a.byVlan[vlanKey] = subnet
+	return subnet
+}
+
+func (a *ipv6Allocator) candidate(strategy IPv6Strategy, nv datatypes.Network_Vlan, taggedSubnets []datatypes.Network_Subnet) (iplib.Net6, net.IP) {
+	switch strategy {
+	case IPv6StrategyTag:
+		return ibmIPv6Subnet(taggedSubnets)
+	case IPv6StrategyVlan:
+		return ibmIPv6Subnet(nv.Subnets)
+	case IPv6StrategyULA:
+		// the vlan id is at most 4094 and always fits in a single hex group
+		return iplib.Net6FromStr(fmt.Sprintf("%s:%x::/64", a.ulaPrefix, *nv.VlanNumber)), nil
+	case IPv6StrategyStatic:
+		for _, s := range a.static {
+			if s.Vlan != *nv.VlanNumber || s.Datacenter != *nv.Datacenter.Name {
+				continue
+			}
+			if s.Pod != "" && s.Pod != *nv.PodName {
+				continue
+			}
+			return iplib.Net6FromStr(s.Prefix), net.ParseIP(s.Gateway)
+		}
+	}
+	return iplib.Net6{}, nil
+}
+
+// overlaps returns the vlan of an allocated prefix overlapping the prefix
+func (a *ipv6Allocator) overlaps(prefix iplib.Net6) string {
+	for _, allocated := range a.allocated {
+		if allocated.prefix.Contains(prefix.IP()) || prefix.Contains(allocated.prefix.IP()) {
+			return allocated.vlan
+		}
+	}
+	return ""
+}
+
+// ibmIPv6Subnet returns the prefix and gateway of the first IPv6 subnet
+func ibmIPv6Subnet(subnets []datatypes.Network_Subnet) (iplib.Net6, net.IP) {
+	for _, s := range subnets {
+		if s.Version == nil || *s.Version != 6 || s.Cidr == nil {
+			continue
+		}
+
+		address := s.NetworkIdentifier
+		if address == nil {
+			address = s.Gateway
+		}
+		if address == nil {
+			continue
+		}
+
+		var gateway net.IP
+		if s.Gateway != nil {
+			gateway = net.ParseIP(*s.Gateway)
+		}
+		return iplib.Net6FromStr(fmt.Sprintf("%s/%d", *address, *s.Cidr)), gateway
+	}
+	return iplib.Net6{}, nil
+}
+
+// newIPv6Subnet picks the gateway and start address the same way for every strategy.
+// The gateway is the one IBM or the static mapping provides, otherwise the second address
+// after the network address as in previous releases. The start address is the fourth
+// address after the network address, or the address after the gateway if the gateway is
+// beyond that.
+func newIPv6Subnet(prefix iplib.Net6, gateway net.IP, strategy IPv6Strategy) *ipv6Subnet {
+	if gateway == nil || !prefix.Contains(gateway) {
+		gateway = prefix.Enumerate(1, 2)[0]
+	}
+
+	start := prefix.Enumerate(1, 4)[0]
+	if bytes.Compare(gateway.To16(), start.To16()) >= 0 {
+		if next, err := prefix.NextIP(gateway); err == nil {
+			start = next
+		}
+	}
+
+	return &ipv6Subnet{
+		Prefix:   prefix,
+		Gateway:  gateway,
+		Start:    start,
+		Strategy: strategy,
+	}
+}
@@ -0,0 +1,64 @@
+package generation
+
+import (
+	"testing"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/sl"
+)
+
+func testVlan(pod string, number int, ipv6Prefix string) datatypes.Network_Vlan {
+	nv := datatypes.Network_Vlan{
+		VlanNumber: sl.Int(number),
+		PodName:    sl.String(pod),
+		Datacenter: &datatypes.Location{Name: sl.String("dal10")},
+	}
+	if ipv6Prefix != "" {
+		nv.Subnets = []datatypes.Network_Subnet{{Version: sl.Int(6), NetworkIdentifier: sl.String(ipv6Prefix), Cidr: sl.Int(64)}}
+	}
+	return nv
+}
+
+func TestIPv6Allocate(t *testing.T) {
+	a, err := newIPv6Allocator(Options{IPv6Subnet: "fd65:a1a8:60ad", IPv6Strategies: []string{string(IPv6StrategyVlan), string(IPv6StrategyULA)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		vlan     datatypes.Network_Vlan
+		prefix   string
+		gateway  string
+		strategy IPv6Strategy
+	}{
+		{"vlan subnet", testVlan("dal10.pod01", 1234, "2001:db8:1::"), "2001:db8:1::/64", "2001:db8:1::2", IPv6StrategyVlan},
+		// e.g. seen from a second vCenter of the pod
+		{"same vlan", testVlan("dal10.pod01", 1234, "2001:db8:1::"), "2001:db8:1::/64", "2001:db8:1::2", IPv6StrategyVlan},
+		{"same number in another pod", testVlan("dal10.pod02", 1234, "2001:db8:2::"), "2001:db8:2::/64", "2001:db8:2::2", IPv6StrategyVlan},
+		{"overlapping another vlan", testVlan("dal10.pod01", 1235, "2001:db8:1::"), "fd65:a1a8:60ad:4d3::/64", "fd65:a1a8:60ad:4d3::2", IPv6StrategyULA},
+		{"without a subnet", testVlan("dal10.pod01", 1236, ""), "fd65:a1a8:60ad:4d4::/64", "fd65:a1a8:60ad:4d4::2", IPv6StrategyULA},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			subnet := a.allocate(tc.vlan, nil)
+			if subnet == nil {
+				t.Fatal("expected a subnet")
+			}
+			if subnet.Prefix.String() != tc.prefix || subnet.Gateway.String() != tc.gateway || subnet.Strategy != tc.strategy {
+				t.Errorf("expected %s gateway %s from %s, got %s gateway %s from %s", tc.prefix, tc.gateway, tc.strategy, subnet.Prefix.String(), subnet.Gateway, subnet.Strategy)
+			}
+		})
+	}
+
+	t.Run("no strategy", func(t *testing.T) {
+		vlanOnly, err := newIPv6Allocator(Options{IPv6Strategies: []string{string(IPv6StrategyVlan)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			if subnet := vlanOnly.allocate(testVlan("dal10.pod01", 1236, ""), nil); subnet != nil {
+				t.Errorf("expected no subnet, got %s", subnet.Prefix.String())
+			}
+		}
+	})
+}
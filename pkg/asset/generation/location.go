@@ -0,0 +1,162 @@
+package generation
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const locationStrategyAnnotation = "vspherecapacitymanager.splat.io/location-strategy"
+
+// LocationStrategy a method of locating the IBM datacenter and pod of a vCenter
+type LocationStrategy string
+
+const (
+	// LocationStrategyOverride uses the vCenter's entry in the location overrides file
+	LocationStrategyOverride LocationStrategy = "override"
+	// LocationStrategyDNS resolves the vCenter hostname
+	LocationStrategyDNS LocationStrategy = "dns"
+	// LocationStrategyGuest uses the guest ip addresses of the vCenter's own virtual machine
+	LocationStrategyGuest LocationStrategy = "guest"
+	// LocationStrategyVpxd uses the config.vpxd.hostnameUrl and managed ip settings
+	LocationStrategyVpxd LocationStrategy = "vpxd"
+	// LocationStrategyBareMetal uses the management addresses of the ESXi hosts, which are
+	// on the vlans of the IBM bare metal servers backing them
+	LocationStrategyBareMetal LocationStrategy = "baremetal"
+)
+
+// DefaultLocationStrategies are tried in order unless configured otherwise
+var DefaultLocationStrategies = []string{
+	string(LocationStrategyOverride),
+	string(LocationStrategyDNS),
+	string(LocationStrategyGuest),
+	string(LocationStrategyVpxd),
+	string(LocationStrategyBareMetal),
+}
+
+// LocationOverride the explicit IBM location of a vCenter
+type LocationOverride struct {
+	Datacenter string `json:"datacenter"`
+	Pod        string `json:"pod"`
+}
+
+// ReadLocationOverrides parses the location overrides file, a map of vCenter to location
+func ReadLocationOverrides(fileName string) (map[string]LocationOverride, error) {
+	overrides := make(map[string]LocationOverride)
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(b, &overrides); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+	return overrides, nil
+}
+
+// vCenterLocator locates vCenters using the configured strategies in order
+type vCenterLocator struct {
+	vmeta      *vsphere.Metadata
+	imeta      *ibmcloud.Metadata
+	accounts   []string
+	strategies []LocationStrategy
+	overrides  map[string]LocationOverride
+}
+
+func newVCenterLocator(vmeta *vsphere.Metadata, imeta *ibmcloud.Metadata, accounts []string, opts Options) (*vCenterLocator, error) {
+	l := &vCenterLocator{
+		vmeta:     vmeta,
+		imeta:     imeta,
+		accounts:  accounts,
+		overrides: make(map[string]LocationOverride),
+	}
+
+	if opts.LocationOverridesFileName != "" {
+		overrides, err := ReadLocationOverrides(opts.LocationOverridesFileName)
+		if err != nil {
+			return nil, err
+		}
+		l.overrides = overrides
+	}
+	// the overrides file takes precedence over the vCenter settings
+	for server, vs := range settings(opts).VCenters {
+		if _, ok := l.overrides[server]; !ok && vs.Location != nil {
+			l.overrides[server] = *vs.Location
+		}
+	}
+
+	strategies := opts.LocationStrategies
+	if len(strategies) == 0 {
+		strategies = DefaultLocationStrategies
+	}
+
+	for _, s := range strategies {
+		switch strategy := LocationStrategy(s); strategy {
+		case LocationStrategyOverride, LocationStrategyDNS, LocationStrategyGuest, LocationStrategyVpxd, LocationStrategyBareMetal:
+			l.strategies = append(l.strategies, strategy)
+		default:
+			return nil, fmt.Errorf("unknown location strategy %s", s)
+		}
+	}
+
+	return l, nil
+}
+
+// locate returns the location found by the first successful strategy, the
+// location is empty if no strategy was able to locate the vCenter.
+func (l *vCenterLocator) locate(server string) (*ibmcloud.VCenterLocation, error) {
+	for _, strategy := range l.strategies {
+		if strategy == LocationStrategyOverride {
+			if o, ok := l.overrides[server]; ok {
+				return &ibmcloud.VCenterLocation{
+					DatacenterName: &o.Datacenter,
+					PodName:        &o.Pod,
+					Strategy:       string(strategy),
+				}, nil
+			}
+			continue
+		}
+
+		ips, err := l.candidateIPs(strategy, server)
+		if err != nil {
+			log.Printf("WARNING: location strategy %s failed for vCenter %s: %v", strategy, server, err)
+			continue
+		}
+		if len(ips) == 0 {
+			continue
+		}
+
+		for _, account := range l.accounts {
+			vcLocation, err := l.imeta.FindVCenterPhyDC(account, ips)
+			if err != nil {
+				return nil, err
+			}
+			if vcLocation.DatacenterName != nil {
+				vcLocation.Strategy = string(strategy)
+				return vcLocation, nil
+			}
+		}
+	}
+
+	return &ibmcloud.VCenterLocation{}, nil
+}
+
+func (l *vCenterLocator) candidateIPs(strategy LocationStrategy, server string) ([]net.IP, error) {
+	switch strategy {
+	case LocationStrategyDNS:
+		return net.LookupIP(server)
+	case LocationStrategyGuest:
+		return l.vmeta.GetVCenterVirtualMachineIPAddresses(server)
+	case LocationStrategyVpxd:
+		return l.vmeta.GetVpxdIPAddresses(server)
+	case LocationStrategyBareMetal:
+		return l.vmeta.GetHostManagementIPAddresses(server)
+	}
+	return nil, nil
+}
@@ -1,10 +1,15 @@
 package generation
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
 	"sigs.k8s.io/yaml"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
 )
 
 // IsManifestDirEmpty determines if the provided directory is empty
@@ -16,14 +21,85 @@ func IsManifestDirEmpty(manifestDir string) (bool, error) {
 	return len(entries) == 0, nil
 }
 
+const (
+	// FormatYAML renders each manifest as a .yaml file
+	FormatYAML = "yaml"
+	// FormatJSON renders each manifest as a .json file
+	FormatJSON = "json"
+)
+
+// ManifestFormats the formats manifests are rendered in
+var ManifestFormats = []string{FormatYAML, FormatJSON}
+
 // WriteManifest writes a manifest to the manifestDir
 func WriteManifest(v any, manifestDir, fileName string) error {
-	path := filepath.Join(manifestDir, fileName)
+	return RenderManifest(v, manifestDir, fileName, FormatYAML)
+}
 
-	marshalled, err := yaml.Marshal(v)
+// RenderManifest writes a manifest in the format to the manifestDir, the extension of the
+// file name is replaced with the format's
+func RenderManifest(v any, manifestDir, fileName, format string) error {
+	var marshalled []byte
+	var err error
+	switch format {
+	case FormatYAML:
+		marshalled, err = yaml.Marshal(v)
+	case FormatJSON:
+		marshalled, err = json.MarshalIndent(v, "", "  ")
+	default:
+		return fmt.Errorf("unknown manifest format %s, must be one of %s", format, strings.Join(ManifestFormats, ", "))
+	}
 	if err != nil {
 		return fmt.Errorf("error while marshalling manifest %s: %w", fileName, err)
 	}
 
-	return os.WriteFile(path, marshalled, 0644)
+	fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "." + format
+	return os.WriteFile(filepath.Join(manifestDir, fileName), marshalled, 0644)
+}
+
+// ReadManifests reads the Pool and Network manifests previously written to the manifestDir in any format
+func ReadManifests(manifestDir string) ([]vcmv1.Pool, []vcmv1.Network, error) {
+	var pools []vcmv1.Pool
+	var networks []vcmv1.Network
+
+	entries, err := os.ReadDir(manifestDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		// json manifests are read by the yaml parser too
+		if e.IsDir() || (!strings.HasSuffix(e.Name(), ".yaml") && !strings.HasSuffix(e.Name(), ".json")) {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(manifestDir, e.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var typeMeta struct {
+			Kind string `json:"kind"`
+		}
+		if err := yaml.Unmarshal(b, &typeMeta); err != nil {
+			return nil, nil, fmt.Errorf("error while unmarshalling manifest %s: %w", e.Name(), err)
+		}
+
+		switch typeMeta.Kind {
+		case vcmv1.PoolKind:
+			var pool vcmv1.Pool
+			if err := yaml.Unmarshal(b, &pool); err != nil {
+				return nil, nil, fmt.Errorf("error while unmarshalling manifest %s: %w", e.Name(), err)
+			}
+			pools = append(pools, pool)
+		case vcmv1.NetworkKind:
+			var network vcmv1.Network
+			if err := yaml.Unmarshal(b, &network); err != nil {
+				return nil, nil, fmt.Errorf("error while unmarshalling manifest %s: %w", e.Name(), err)
+			}
+			networks = append(networks, network)
+		}
+	}
+
+	return pools, networks, nil
 }
@@ -0,0 +1,188 @@
+package generation
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+)
+
+// VlanWithoutSubnet an IBM vlan with a matching port group that produces no Network
+// because none of its IPv4 subnets has a usable ip address
+type VlanWithoutSubnet struct {
+	Account    string `json:"account"`
+	Datacenter string `json:"datacenter"`
+	Pod        string `json:"pod"`
+
+	// VlanId is the IBM object id of the vlan
+	VlanId     int `json:"vlanId"`
+	VlanNumber int `json:"vlanNumber"`
+
+	// PortGroups the port groups of the vlan, one per vCenter of the pod with one
+	PortGroups []ServerPortGroup `json:"portGroups"`
+}
+
+// ServerPortGroup a port group of a vCenter
+type ServerPortGroup struct {
+	Server    string `json:"server"`
+	PortGroup string `json:"portGroup"`
+}
+
+// vlansWithoutSubnets the vlans without subnets by account and IBM object id, a vlan seen
+// from several vCenters of its pod is only listed, and ordered, once
+type vlansWithoutSubnets map[string]*VlanWithoutSubnet
+
+// add records the vlan and the port group of the server
+func (v vlansWithoutSubnets) add(vlan VlanWithoutSubnet, pg ServerPortGroup) {
+	key := fmt.Sprintf("%s/%d", vlan.Account, vlan.VlanId)
+	existing, ok := v[key]
+	if !ok {
+		existing = &vlan
+		existing.PortGroups = nil
+		v[key] = existing
+	}
+	existing.PortGroups = append(existing.PortGroups, pg)
+}
+
+// sorted returns the vlans sorted by account, datacenter, pod and vlan number with their
+// port groups sorted by server
+func (v vlansWithoutSubnets) sorted() []VlanWithoutSubnet {
+	vlans := make([]VlanWithoutSubnet, 0, len(v))
+	for _, vlan := range v {
+		sort.Slice(vlan.PortGroups, func(i, j int) bool {
+			if vlan.PortGroups[i].Server != vlan.PortGroups[j].Server {
+				return vlan.PortGroups[i].Server < vlan.PortGroups[j].Server
+			}
+			return vlan.PortGroups[i].PortGroup < vlan.PortGroups[j].PortGroup
+		})
+		vlans = append(vlans, *vlan)
+	}
+
+	sort.Slice(vlans, func(i, j int) bool {
+		a, b := vlans[i], vlans[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Datacenter != b.Datacenter {
+			return a.Datacenter < b.Datacenter
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		if a.VlanNumber != b.VlanNumber {
+			return a.VlanNumber < b.VlanNumber
+		}
+		return a.VlanId < b.VlanId
+	})
+	return vlans
+}
+
+// FindVlansWithoutSubnets locates every vCenter and returns the vlans of its port groups
+// that would not produce a Network. A vlan is returned once with the port groups of every
+// vCenter, sorted by account, datacenter, pod and vlan number.
+func FindVlansWithoutSubnets(opts Options) ([]VlanWithoutSubnet, error) {
+	var reservations ibmcloud.Reservations
+	missing := make(vlansWithoutSubnets)
+
+	vmeta := newVSphereMetadata(opts)
+	defer vmeta.Logout()
+
+	if opts.ReservationsFileName != "" {
+		var err error
+		reservations, err = ibmcloud.ReadReservations(opts.ReservationsFileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subnetTypes := opts.SubnetTypes
+	if len(subnetTypes) == 0 {
+		subnetTypes = DefaultSubnetTypes
+	}
+
+	imeta, accounts, err := NewIBMCloudMetadata(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	locator, err := newVCenterLocator(vmeta, imeta, accounts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vcenterCredentials, err := vsphereCredentials(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range vcenterCredentials {
+		if _, err := vmeta.AddVCenterCredential(k, v); err != nil {
+			return nil, err
+		}
+
+		portGroupSubnetsMap, err := portGroupSubnets(vmeta, k, opts.PortGroupNameSubstring)
+		if err != nil {
+			return nil, err
+		}
+
+		vcLocation, err := locator.locate(k)
+		if err != nil {
+			return nil, err
+		}
+		if vcLocation.DatacenterName == nil {
+			log.Printf("WARNING: unable to find physcial location of vCenter %s using the %v location strategies", k, locator.strategies)
+			continue
+		}
+
+		for _, account := range settings(opts).accountsIn(accounts, *vcLocation.DatacenterName) {
+			networkVlans, err := imeta.GetVlanSubnets(account, *vcLocation.DatacenterName, *vcLocation.PodName)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, nv := range *networkVlans {
+				pg, ok := portGroupSubnetsMap[int32(*nv.VlanNumber)]
+				if !ok {
+					continue
+				}
+
+				vlanTags, err := ibmcloud.ParseTags(ibmcloud.TagNames(nv.TagReferences))
+				if err != nil || vlanTags.Exclude {
+					continue
+				}
+
+				tagged, err := imeta.GetVlanTaggedSubnets(account, *vcLocation.DatacenterName, *vcLocation.PodName, *nv.VlanNumber)
+				if err != nil {
+					return nil, err
+				}
+
+				subnets, _ := vlanNetworkSubnets(nv, vlanTags, tagged, subnetTypes)
+				usable := false
+				for _, ns := range subnets {
+					if len(ibmcloud.UsableIPAddresses(ns.subnet, 0, reservations)) > 0 {
+						usable = true
+						break
+					}
+				}
+				if usable {
+					continue
+				}
+
+				missing.add(VlanWithoutSubnet{
+					Account:    account,
+					Datacenter: *vcLocation.DatacenterName,
+					Pod:        *vcLocation.PodName,
+					VlanId:     *nv.Id,
+					VlanNumber: *nv.VlanNumber,
+				}, ServerPortGroup{Server: k, PortGroup: pg.Name})
+			}
+
+			if len(*networkVlans) > 0 {
+				break
+			}
+		}
+	}
+
+	return missing.sorted(), nil
+}
@@ -0,0 +1,49 @@
+package generation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVlansWithoutSubnets(t *testing.T) {
+	missing := make(vlansWithoutSubnets)
+
+	vlan := func(account string, id, number int, pod string) VlanWithoutSubnet {
+		return VlanWithoutSubnet{Account: account, Datacenter: "dal10", Pod: pod, VlanId: id, VlanNumber: number}
+	}
+
+	// vlan 1234 of dal10.pod01 has a port group on two vCenters of the pod
+	missing.add(vlan("account", 101, 1234, "dal10.pod01"), ServerPortGroup{Server: "vc2.example.com", PortGroup: "ci-vlan-1234"})
+	missing.add(vlan("account", 100, 1235, "dal10.pod01"), ServerPortGroup{Server: "vc1.example.com", PortGroup: "ci-vlan-1235"})
+	missing.add(vlan("account", 101, 1234, "dal10.pod01"), ServerPortGroup{Server: "vc1.example.com", PortGroup: "ci-vlan-1234"})
+	// the same vlan number in another pod is another vlan
+	missing.add(vlan("account", 200, 1234, "dal10.pod02"), ServerPortGroup{Server: "vc3.example.com", PortGroup: "ci-vlan-1234"})
+	// and so is the same vlan id of another account
+	missing.add(vlan("other", 101, 1234, "dal10.pod01"), ServerPortGroup{Server: "vc1.example.com", PortGroup: "ci-vlan-1234"})
+
+	expected := []VlanWithoutSubnet{
+		{
+			Account: "account", Datacenter: "dal10", Pod: "dal10.pod01", VlanId: 101, VlanNumber: 1234,
+			PortGroups: []ServerPortGroup{
+				{Server: "vc1.example.com", PortGroup: "ci-vlan-1234"},
+				{Server: "vc2.example.com", PortGroup: "ci-vlan-1234"},
+			},
+		},
+		{
+			Account: "account", Datacenter: "dal10", Pod: "dal10.pod01", VlanId: 100, VlanNumber: 1235,
+			PortGroups: []ServerPortGroup{{Server: "vc1.example.com", PortGroup: "ci-vlan-1235"}},
+		},
+		{
+			Account: "account", Datacenter: "dal10", Pod: "dal10.pod02", VlanId: 200, VlanNumber: 1234,
+			PortGroups: []ServerPortGroup{{Server: "vc3.example.com", PortGroup: "ci-vlan-1234"}},
+		},
+		{
+			Account: "other", Datacenter: "dal10", Pod: "dal10.pod01", VlanId: 101, VlanNumber: 1234,
+			PortGroups: []ServerPortGroup{{Server: "vc1.example.com", PortGroup: "ci-vlan-1234"}},
+		},
+	}
+
+	if got := missing.sorted(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
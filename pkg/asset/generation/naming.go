@@ -0,0 +1,261 @@
+package generation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+const (
+	originalNameAnnotation      = "vspherecapacitymanager.splat.io/original-name"
+	vsphereDatacenterAnnotation = "vspherecapacitymanager.splat.io/vsphere-datacenter"
+	vsphereClusterAnnotation    = "vspherecapacitymanager.splat.io/vsphere-cluster"
+
+	// MaxNameLength the length of a DNS-1123 subdomain, the limit of object names
+	MaxNameLength = 253
+	// minNameLength leaves room for a prefix before the hash suffix of truncated names
+	minNameLength    = 16
+	hashSuffixLength = 8
+)
+
+// NamingTemplates the Go templates of the Pool, Network and manifest file names. The names are
+// sanitised to DNS-1123 subdomains and names longer than MaxLength are truncated with a hash suffix.
+type NamingTemplates struct {
+	// Pool is executed with PoolNameData
+	Pool string
+	// Network is executed with NetworkNameData
+	Network string
+	// PoolFile and NetworkFile are executed with FileNameData
+	PoolFile    string
+	NetworkFile string
+	// MaxLength of the Pool and Network names, 63 keeps them valid label values
+	MaxLength int
+}
+
+// DefaultNamingTemplates the names of previous releases
+func DefaultNamingTemplates() NamingTemplates {
+	return NamingTemplates{
+		Pool:        "{{.Name}}",
+		Network:     "{{.PortGroup}}-{{.Datacenter}}-{{.Pod}}{{with .Subnet}}-{{.}}{{end}}",
+		PoolFile:    "pool-{{.Name}}.yaml",
+		NetworkFile: "network-{{.Name}}.yaml",
+		MaxLength:   MaxNameLength,
+	}
+}
+
+// PoolNameData the fields of the Pool name template
+type PoolNameData struct {
+	// Name the failure domain name, <server>-<datacenter>-<cluster>[-<zone>]
+	Name       string
+	Server     string
+	Datacenter string
+	Cluster    string
+	Region     string
+	Zone       string
+}
+
+// NetworkNameData the fields of the Network name template
+type NetworkNameData struct {
+	Server     string
+	PortGroup  string
+	VlanNumber int
+	// Datacenter and Pod the IBM datacenter and pod of the vlan
+	Datacenter string
+	Pod        string
+	// Subnet the CIDR of the additional subnets of a vlan, empty for the first
+	Subnet string
+}
+
+// FileNameData the fields of the manifest file name templates
+type FileNameData struct {
+	Kind string
+	Name string
+}
+
+var namingFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"base":       path.Base,
+}
+
+// namer renders the names of a run and detects names and file names used twice
+type namer struct {
+	pool, network, poolFile, networkFile *template.Template
+	maxLength                            int
+
+	// names the original of each name by kind, objects the name of each object by kind and
+	// files the name of each lower case file name
+	names   map[string]map[string]string
+	objects map[string]map[string]string
+	files   map[string]string
+}
+
+// Validate parses the templates and checks the max length
+func (t NamingTemplates) Validate() error {
+	_, err := newNamer(t)
+	return err
+}
+
+func newNamer(t NamingTemplates) (*namer, error) {
+	defaults := DefaultNamingTemplates()
+	n := &namer{
+		maxLength: t.MaxLength,
+		names:     make(map[string]map[string]string),
+		objects:   make(map[string]map[string]string),
+		files:     make(map[string]string),
+	}
+	if n.maxLength == 0 {
+		n.maxLength = defaults.MaxLength
+	}
+	if n.maxLength < minNameLength || n.maxLength > MaxNameLength {
+		return nil, fmt.Errorf("the max name length must be between %d and %d", minNameLength, MaxNameLength)
+	}
+
+	for _, tmpl := range []struct {
+		name  string
+		text  string
+		deflt string
+		t     **template.Template
+	}{
+		{"pool", t.Pool, defaults.Pool, &n.pool},
+		{"network", t.Network, defaults.Network, &n.network},
+		{"poolFile", t.PoolFile, defaults.PoolFile, &n.poolFile},
+		{"networkFile", t.NetworkFile, defaults.NetworkFile, &n.networkFile},
+	} {
+		text := tmpl.text
+		if text == "" {
+			text = tmpl.deflt
+		}
+		parsed, err := template.New(tmpl.name).Funcs(namingFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s name template: %w", tmpl.name, err)
+		}
+		*tmpl.t = parsed
+	}
+	return n, nil
+}
+
+// poolName returns the sanitised name and the rendered original of the failure domain's Pool
+func (n *namer) poolName(fd configv1.VSpherePlatformFailureDomainSpec) (string, string, error) {
+	original, err := execute(n.pool, PoolNameData{
+		Name:       fd.Name,
+		Server:     fd.Server,
+		Datacenter: path.Base(fd.Topology.Datacenter),
+		Cluster:    path.Base(fd.Topology.ComputeCluster),
+		Region:     fd.Region,
+		Zone:       fd.Zone,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("unable to name the Pool of failure domain %s: %w", fd.Name, err)
+	}
+	return SanitizeName(original, n.maxLength), original, nil
+}
+
+// networkName returns the sanitised name and the rendered original of a Network
+func (n *namer) networkName(data NetworkNameData) (string, string, error) {
+	original, err := execute(n.network, data)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to name the Network of port group %s: %w", data.PortGroup, err)
+	}
+	return SanitizeName(original, n.maxLength), original, nil
+}
+
+// register records the name of the object of the kind, the object identifies what the name is
+// generated from, e.g. the IBM vlan and subnet of a Network. It returns false for an object
+// registered before, e.g. a vlan seen from two vCenters of its pod, and an error for a name
+// used by two different objects.
+func (n *namer) register(kind, name, original, object string) (bool, error) {
+	if n.names[kind] == nil {
+		n.names[kind] = make(map[string]string)
+		n.objects[kind] = make(map[string]string)
+	}
+	if _, ok := n.objects[kind][object]; ok {
+		return false, nil
+	}
+	if other, ok := n.names[kind][name]; ok {
+		return false, fmt.Errorf("%s name %s of %s collides with %s", kind, name, original, other)
+	}
+	n.names[kind][name] = original
+	n.objects[kind][object] = name
+	return true, nil
+}
+
+// fileName returns the manifest file name of the object, file names differing only in case collide
+func (n *namer) fileName(kind, name string) (string, error) {
+	tmpl := n.poolFile
+	if kind == vcmv1.NetworkKind {
+		tmpl = n.networkFile
+	}
+	fileName, err := execute(tmpl, FileNameData{Kind: kind, Name: name})
+	if err != nil {
+		return "", fmt.Errorf("unable to name the file of %s %s: %w", kind, name, err)
+	}
+	if fileName == "" || strings.ContainsAny(fileName, `/\`) || fileName == "." || fileName == ".." {
+		return "", fmt.Errorf("file name %q of %s %s is not a file in the manifests directory", fileName, kind, name)
+	}
+
+	key := strings.ToLower(fileName)
+	if other, ok := n.files[key]; ok {
+		return "", fmt.Errorf("file name %s of %s %s collides with %s", fileName, kind, name, other)
+	}
+	n.files[key] = fmt.Sprintf("%s %s", kind, name)
+	return fileName, nil
+}
+
+func execute(t *template.Template, data any) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// SanitizeName returns the name as a DNS-1123 subdomain: lower case, characters other than
+// alphanumerics, '-' and '.' replaced with '-', and every dot separated label starting and
+// ending alphanumeric. Names longer than maxLength are truncated and suffixed with a hash of
+// the original, so distinct long names stay distinct.
+func SanitizeName(name string, maxLength int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+
+	var labels []string
+	for _, label := range strings.Split(b.String(), ".") {
+		if label = strings.Trim(label, "-"); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	sanitized := strings.Join(labels, ".")
+
+	if sanitized != "" && len(sanitized) <= maxLength {
+		return sanitized
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashSuffixLength]
+	prefix := sanitized
+	if len(prefix) > maxLength-hashSuffixLength-1 {
+		prefix = prefix[:maxLength-hashSuffixLength-1]
+	}
+	prefix = strings.TrimRight(prefix, "-.")
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
@@ -0,0 +1,226 @@
+package generation
+
+import (
+	"strings"
+	"testing"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+	configv1 "github.com/openshift/api/config/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSanitizeName(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		input     string
+		maxLength int
+		expected  string
+	}{
+		{"valid", "vcenter.example.com-dc1-cluster1", MaxNameLength, "vcenter.example.com-dc1-cluster1"},
+		{"uppercase", "VCenter.Example.COM-DC1", MaxNameLength, "vcenter.example.com-dc1"},
+		{"spaces", "DC 1 cluster  A", MaxNameLength, "dc-1-cluster--a"},
+		{"underscores", "DC0_C0_H0", MaxNameLength, "dc0-c0-h0"},
+		{"mixed", "vc1.Example.com-DC 1-Cluster_A", MaxNameLength, "vc1.example.com-dc-1-cluster-a"},
+		{"leading and trailing dashes", "-ci-vlan-1234-", MaxNameLength, "ci-vlan-1234"},
+		{"dashes around dots", "vc1-.-example-.com-", MaxNameLength, "vc1.example.com"},
+		{"empty labels", "--a..b--", MaxNameLength, "a.b"},
+		{"characters of a path", "/DC0/host/DC0_C0", MaxNameLength, "dc0-host-dc0-c0"},
+		{"empty", "", MaxNameLength, "e3b0c442"},
+		{"no valid characters", "___", MaxNameLength, "bda25155"},
+		{"too long", strings.Repeat("x", 300), 63, strings.Repeat("x", 54) + "-0d4e2ca9"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SanitizeName(tc.input, tc.maxLength); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeNameValid(t *testing.T) {
+	long := strings.Repeat("Cluster_", 40)
+	inputs := []string{
+		"", "-", ".", "_._", "UPPER CASE", "-leading", "trailing-", "a.-b.c-",
+		long + "1", long + "2", long + ".-", strings.Repeat("a.", 200),
+	}
+
+	for _, maxLength := range []int{minNameLength, 63, MaxNameLength} {
+		seen := make(map[string]string)
+		for _, input := range inputs {
+			name := SanitizeName(input, maxLength)
+			if len(name) > maxLength {
+				t.Errorf("max length %d: %q is sanitised to %q of length %d", maxLength, input, name, len(name))
+			}
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+				t.Errorf("max length %d: %q is sanitised to the invalid %q: %v", maxLength, input, name, errs)
+			}
+			if other, ok := seen[name]; ok {
+				t.Errorf("max length %d: %q and %q are both sanitised to %q", maxLength, other, input, name)
+			}
+			seen[name] = input
+		}
+	}
+}
+
+func TestNewNamer(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		templates NamingTemplates
+		err       string
+	}{
+		{"defaults", NamingTemplates{}, ""},
+		{"label length", NamingTemplates{MaxLength: 63}, ""},
+		{"too short", NamingTemplates{MaxLength: minNameLength - 1}, "max name length"},
+		{"too long", NamingTemplates{MaxLength: MaxNameLength + 1}, "max name length"},
+		{"invalid template", NamingTemplates{Pool: "{{.Name"}, "invalid pool name template"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.templates.Validate()
+			if tc.err == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestDefaultNames(t *testing.T) {
+	n, err := newNamer(DefaultNamingTemplates())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd := configv1.VSpherePlatformFailureDomainSpec{
+		Name:   "vcenter.ci.ibmc.devcluster.openshift.com-cidatacenter-cicluster",
+		Server: "vcenter.ci.ibmc.devcluster.openshift.com",
+		Topology: configv1.VSpherePlatformTopology{
+			Datacenter:     "cidatacenter",
+			ComputeCluster: "/cidatacenter/host/cicluster",
+		},
+	}
+	poolName, original, err := n.poolName(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poolName != fd.Name || original != fd.Name {
+		t.Errorf("expected Pool %s, got %s from %s", fd.Name, poolName, original)
+	}
+	poolFile, err := n.fileName(vcmv1.PoolKind, poolName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "pool-" + fd.Name + ".yaml"; poolFile != expected {
+		t.Errorf("expected file %s, got %s", expected, poolFile)
+	}
+
+	for _, tc := range []struct {
+		data     NetworkNameData
+		expected string
+		file     string
+	}{
+		{
+			data:     NetworkNameData{PortGroup: "ci-vlan-1234", Datacenter: "dal10", Pod: "dal10.pod03"},
+			expected: "ci-vlan-1234-dal10-dal10.pod03",
+			file:     "network-ci-vlan-1234-dal10-dal10.pod03.yaml",
+		},
+		{
+			data:     NetworkNameData{PortGroup: "ci-vlan-1234", Datacenter: "dal10", Pod: "dal10.pod03", Subnet: "10.0.0.64/26"},
+			expected: "ci-vlan-1234-dal10-dal10.pod03-10.0.0.64-26",
+			file:     "network-ci-vlan-1234-dal10-dal10.pod03-10.0.0.64-26.yaml",
+		},
+	} {
+		name, _, err := n.networkName(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != tc.expected {
+			t.Errorf("expected Network %s, got %s", tc.expected, name)
+		}
+		file, err := n.fileName(vcmv1.NetworkKind, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file != tc.file {
+			t.Errorf("expected file %s, got %s", tc.file, file)
+		}
+	}
+}
+
+func TestNamerCollisions(t *testing.T) {
+	t.Run("names", func(t *testing.T) {
+		n, err := newNamer(DefaultNamingTemplates())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			kind       string
+			name       string
+			original   string
+			object     string
+			registered bool
+			err        string
+		}{
+			{vcmv1.PoolKind, "vc-cluster-a", "vc-cluster_a", "vc/vc-cluster_a", true, ""},
+			// the kinds have separate names
+			{vcmv1.NetworkKind, "vc-cluster-a", "vc-cluster_a", "vlan 100 subnet 200", true, ""},
+			// another object with the name collides
+			{vcmv1.PoolKind, "vc-cluster-a", "vc cluster a", "vc/vc cluster a", false, "Pool name vc-cluster-a of vc cluster a collides with vc-cluster_a"},
+			// the same vlan and subnet seen from another vCenter of the pod is only registered once
+			{vcmv1.NetworkKind, "vc-cluster-a", "vc-cluster_a", "vlan 100 subnet 200", false, ""},
+			{vcmv1.NetworkKind, "ci-vlan-1234-dal10-dal10.pod01", "ci-vlan-1234-dal10-dal10.pod01", "vlan 100 subnet 200", false, ""},
+			{vcmv1.NetworkKind, "vc-cluster-b", "vc-cluster_b", "vlan 100 subnet 201", true, ""},
+		} {
+			registered, err := n.register(tc.kind, tc.name, tc.original, tc.object)
+			if tc.err == "" && err != nil {
+				t.Fatalf("%s %s: expected no error, got %v", tc.kind, tc.object, err)
+			}
+			if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Fatalf("%s %s: expected %q, got %v", tc.kind, tc.object, tc.err, err)
+			}
+			if registered != tc.registered {
+				t.Errorf("%s %s: expected registered %v, got %v", tc.kind, tc.object, tc.registered, registered)
+			}
+		}
+	})
+
+	for _, tc := range []struct {
+		name   string
+		first  string
+		second string
+		err    string
+	}{
+		{"same name", "vc-cluster-a", "vc-cluster-a", "file name pool-vc-cluster-a.yaml of Pool vc-cluster-a collides with Pool vc-cluster-a"},
+		{"case only", "vc-cluster-a", "VC-CLUSTER-A", "file name pool-VC-CLUSTER-A.yaml of Pool VC-CLUSTER-A collides with Pool vc-cluster-a"},
+		{"distinct", "vc-cluster-a", "vc-cluster-b", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := newNamer(DefaultNamingTemplates())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := n.fileName(vcmv1.PoolKind, tc.first); err != nil {
+				t.Fatal(err)
+			}
+			_, err = n.fileName(vcmv1.PoolKind, tc.second)
+			if tc.err == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Fatalf("expected %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	t.Run("file outside the manifests directory", func(t *testing.T) {
+		n, err := newNamer(NamingTemplates{PoolFile: "../{{.Name}}.yaml"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := n.fileName(vcmv1.PoolKind, "pool"); err == nil {
+			t.Error("expected a file name with a path to be refused")
+		}
+	})
+}
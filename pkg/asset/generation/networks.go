@@ -0,0 +1,137 @@
+package generation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/sl"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+)
+
+const (
+	SubnetTypePrimary         = "PRIMARY"
+	SubnetTypeSecondaryOnVlan = "SECONDARY_ON_VLAN"
+	SubnetTypeSubnetOnVlan    = "SUBNET_ON_VLAN"
+)
+
+// DefaultSubnetTypes are the IBM subnet types that produce a Network unless configured otherwise
+var DefaultSubnetTypes = []string{SubnetTypePrimary, SubnetTypeSecondaryOnVlan, SubnetTypeSubnetOnVlan}
+
+// selectSubnets returns the IPv4 subnets of the vlan matching one of the subnet types.
+// The primary subnet is always first, the remaining subnets are ordered by network address.
+func selectSubnets(subnets []datatypes.Network_Subnet, subnetTypes []string) []datatypes.Network_Subnet {
+	selected := make([]datatypes.Network_Subnet, 0, len(subnets))
+
+	for _, s := range subnets {
+		if s.Version != nil && *s.Version != 4 {
+			continue
+		}
+		if s.SubnetType == nil || s.NetworkIdentifier == nil || s.Cidr == nil {
+			continue
+		}
+		for _, t := range subnetTypes {
+			if strings.EqualFold(*s.SubnetType, t) {
+				selected = append(selected, s)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		iPrimary := *selected[i].SubnetType == SubnetTypePrimary
+		jPrimary := *selected[j].SubnetType == SubnetTypePrimary
+		if iPrimary != jPrimary {
+			return iPrimary
+		}
+		return bytes.Compare(net.ParseIP(*selected[i].NetworkIdentifier).To16(), net.ParseIP(*selected[j].NetworkIdentifier).To16()) < 0
+	})
+
+	return selected
+}
+
+// subnetNameSuffix returns the CIDR of the subnet in a form usable in a
+// resource name, e.g. 10.0.0.0/26 becomes 10-0-0-0-26.
+func subnetNameSuffix(subnet datatypes.Network_Subnet) string {
+	return fmt.Sprintf("%s-%d", strings.NewReplacer(".", "-", ":", "-").Replace(*subnet.NetworkIdentifier), *subnet.Cidr)
+}
+
+// errVIPAllocation the subnet has too few usable ip addresses for the VIP pairs, its
+// Network is skipped rather than generated without VIPs.
+var errVIPAllocation = errors.New("unable to allocate VIPs")
+
+// newNetwork creates the Network of a single subnet on the port group's vlan, nil is
+// returned if the subnet has no usable ip addresses and errVIPAllocation if the VIP
+// pairs do not fit in the subnet.
+func newNetwork(name string, pg PortGroupSubnet, nv datatypes.Network_Vlan, subnet datatypes.Network_Subnet, reservations ibmcloud.Reservations, opts Options) (*vcmv1.Network, error) {
+	usableIPAddresses := ibmcloud.UsableIPAddresses(subnet, 0, reservations)
+	if len(usableIPAddresses) == 0 {
+		log.Printf("WARNING: no usable ip addresses in subnet %s/%d vlan %d", *subnet.NetworkIdentifier, *subnet.Cidr, *nv.VlanNumber)
+		return nil, nil
+	}
+
+	// VIPs are reserved before the count is applied so they never
+	// overlap the node addresses and do not move when the count changes.
+	vips, usableIPAddresses, err := allocateVIPs(usableIPAddresses, opts.VIPPairs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errVIPAllocation, err)
+	}
+	if opts.IPAddressCount > 0 && opts.IPAddressCount < len(usableIPAddresses) {
+		usableIPAddresses = usableIPAddresses[:opts.IPAddressCount]
+	}
+
+	annotations, err := vipAnnotations(vips)
+	if err != nil {
+		return nil, err
+	}
+
+	ipAddressesAsString := make([]string, 0, len(usableIPAddresses))
+	for _, ipAddress := range usableIPAddresses {
+		ipAddressesAsString = append(ipAddressesAsString, ipAddress.String())
+	}
+
+	network := &vcmv1.Network{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Network",
+			APIVersion: currentRunningGroupNameAndVersion,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: vcmv1.NetworkSpec{
+			PortGroupName:         pg.Name,
+			VlanId:                strconv.Itoa(*nv.VlanNumber),
+			PodName:               nv.PodName,
+			DatacenterName:        nv.Datacenter.Name,
+			Cidr:                  subnet.Cidr,
+			Gateway:               subnet.Gateway,
+			IpAddressCount:        sl.Uint(uint(len(ipAddressesAsString))),
+			Netmask:               subnet.Netmask,
+			SubnetType:            subnet.SubnetType,
+			MachineNetworkCidr:    fmt.Sprintf("%s/%d", *subnet.NetworkIdentifier, *subnet.Cidr),
+			IpAddresses:           ipAddressesAsString,
+			PrimaryRouterHostname: *nv.PrimaryRouter.Hostname,
+		},
+	}
+
+	return network, nil
+}
+
+// setIPv6Subnet sets the IPv6 addressing of the Network
+func setIPv6Subnet(network *vcmv1.Network, ipv6Subnet *ipv6Subnet) {
+	network.Spec.CidrIPv6, _ = ipv6Subnet.Prefix.Mask().Size()
+	network.Spec.GatewayIPv6 = ipv6Subnet.Gateway.String()
+	network.Spec.IpV6prefix = ipv6Subnet.Prefix.String()
+	network.Spec.StartIPv6Address = ipv6Subnet.Start.String()
+	network.Annotations[ipv6StrategyAnnotation] = string(ipv6Subnet.Strategy)
+}
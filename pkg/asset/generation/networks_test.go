@@ -0,0 +1,78 @@
+package generation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/sl"
+)
+
+// testSubnet returns the 10.0.0.0/29 subnet with the addresses 10.0.0.1 to 10.0.0.6 and their notes
+func testSubnet(notes map[int]string) datatypes.Network_Subnet {
+	subnet := datatypes.Network_Subnet{
+		Id:                sl.Int(1),
+		NetworkIdentifier: sl.String("10.0.0.0"),
+		Cidr:              sl.Int(29),
+		Gateway:           sl.String("10.0.0.1"),
+		BroadcastAddress:  sl.String("10.0.0.7"),
+		Netmask:           sl.String("255.255.255.248"),
+		SubnetType:        sl.String(SubnetTypePrimary),
+		IpAddressCount:    sl.Uint(8),
+	}
+	for i := 1; i <= 6; i++ {
+		ipAddress := datatypes.Network_Subnet_IpAddress{IpAddress: sl.String(fmt.Sprintf("10.0.0.%d", i))}
+		if note, ok := notes[i]; ok {
+			ipAddress.Note = sl.String(note)
+		}
+		subnet.IpAddresses = append(subnet.IpAddresses, ipAddress)
+	}
+	return subnet
+}
+
+func TestNewNetwork(t *testing.T) {
+	nv := testVlan("dal10.pod01", 1234, "")
+	nv.PrimaryRouter = &datatypes.Hardware_Router{Hardware_Switch: datatypes.Hardware_Switch{Hardware: datatypes.Hardware{Hostname: sl.String("bcr01a.dal10")}}}
+	pg := PortGroupSubnet{Name: "ci-vlan-1234"}
+
+	for _, tc := range []struct {
+		name        string
+		notes       map[int]string
+		vipPairs    int
+		count       int
+		ipAddresses []string
+		vips        string
+		err         error
+	}{
+		{"no VIPs", nil, 0, 0, []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}, "", nil},
+		{"VIPs", nil, 1, 0, []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"}, `[{"api":"10.0.0.5","ingress":"10.0.0.6"}]`, nil},
+		{"reserved", map[int]string{2: "Reserved for HSRP.", 3: "not reserved"}, 1, 0, []string{"10.0.0.3", "10.0.0.4"}, `[{"api":"10.0.0.5","ingress":"10.0.0.6"}]`, nil},
+		{"count", nil, 1, 2, []string{"10.0.0.2", "10.0.0.3"}, `[{"api":"10.0.0.5","ingress":"10.0.0.6"}]`, nil},
+		// the Network is skipped rather than generated without VIPs
+		{"too many VIPs", nil, 3, 0, nil, "", errVIPAllocation},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			network, err := newNetwork("network", pg, nv, testSubnet(tc.notes), nil, Options{VIPPairs: tc.vipPairs, IPAddressCount: tc.count})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if tc.err != nil {
+				if network != nil {
+					t.Errorf("expected no Network, got %s", network.Name)
+				}
+				return
+			}
+			if fmt.Sprint(network.Spec.IpAddresses) != fmt.Sprint(tc.ipAddresses) {
+				t.Errorf("expected ip addresses %v, got %v", tc.ipAddresses, network.Spec.IpAddresses)
+			}
+			// the count of the subnet includes the network, gateway, broadcast and reserved addresses
+			if network.Spec.IpAddressCount == nil || *network.Spec.IpAddressCount != uint(len(tc.ipAddresses)) {
+				t.Errorf("expected an ip address count of %d, got %v", len(tc.ipAddresses), network.Spec.IpAddressCount)
+			}
+			if vips := network.Annotations[vipsAnnotation]; vips != tc.vips {
+				t.Errorf("expected VIPs %q, got %q", tc.vips, vips)
+			}
+		})
+	}
+}
@@ -1,19 +1,19 @@
 package generation
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/c-robinson/iplib/v2"
 	"github.com/softlayer/softlayer-go/datatypes"
-	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/credentials"
 	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
 	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
 	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
@@ -43,79 +43,236 @@ type FailureDomainResourceCapacity struct {
 	Name        string
 }
 
+// Options configures the generation of the vSphere environments config
+type Options struct {
+	VCenterAuthFileName    string
+	IBMCloudAuthFileName   string
+	IPv6Subnet             string
+	PortGroupNameSubstring string
+
+	// IPAddressCount is the maximum number of ip addresses included in
+	// each Network, zero or less includes the whole usable range.
+	IPAddressCount int
+
+	// VIPPairs is the number of API and Ingress VIP pairs reserved on each
+	// Network, one pair per concurrent cluster.
+	VIPPairs int
+
+	// IPv6Strategies are tried in order to determine the IPv6 subnet of each vlan
+	IPv6Strategies []string
+
+	// IPv6StaticFileName is the mapping file used by the static IPv6 strategy
+	IPv6StaticFileName string
+
+	// PageSize is the number of objects retrieved per IBM Cloud api request
+	PageSize int
+
+	// LocationStrategies are tried in order to locate the IBM datacenter and pod of each vCenter
+	LocationStrategies []string
+
+	// LocationOverridesFileName optionally points to the explicit locations of vCenters
+	LocationOverridesFileName string
+
+	// HostInventory maps the ESXi hosts of each Pool to IBM bare metal servers in the run report
+	HostInventory bool
+
+	// Costs attributes the recurring monthly SoftLayer fees of the bare metal servers,
+	// vlans and subnets to each Pool and Network
+	Costs bool
+
+	// Cache optionally persists IBM Cloud and vCenter lookups between runs
+	Cache *cache.Cache
+
+	// SubnetTypes are the IBM subnet types of a vlan that produce a Network,
+	// e.g. PRIMARY, SECONDARY_ON_VLAN and SUBNET_ON_VLAN.
+	SubnetTypes []string
+
+	// ReservationsFileName optionally points to a file of ip addresses and
+	// CIDRs that must never be included in a Network.
+	ReservationsFileName string
+
+	// VSphereSessionCacheDir optionally persists the vCenter session cookies between runs
+	VSphereSessionCacheDir string
+
+	// VSphereKeepAlive is the idle time between vCenter keep alive requests, zero disables them
+	VSphereKeepAlive time.Duration
+
+	// Credentials optionally resolves the vCenter and IBM Cloud credentials instead of the auth files
+	Credentials *credentials.Chain
+
+	// Settings optionally holds the per vCenter and account settings, selection, capacity model and policy
+	Settings *Settings
+
+	// TagLayout optionally replaces the default tag categories and tagged object types of failure domains
+	TagLayout *vsphere.TagLayout
+}
+
 type VSphereEnvironmentsConfig struct {
 	configv1.VSpherePlatformSpec
 	PortGroupSubnets               []PortGroupSubnet
 	FailureDomainsResourceCapacity []FailureDomainResourceCapacity
 }
 
-func parseIBMCredentails(ibmCloudAuthFileName string) (map[string]ibmcloud.SoftlayerCredentials, error) {
-	ibmCredentails := make(map[string]ibmcloud.SoftlayerCredentials)
+// ParseIBMCredentials reads the IBM Cloud JSON auth file, an encrypted file is decrypted with the VCMD_* key variables
+func ParseIBMCredentials(ibmCloudAuthFileName string) (map[string]ibmcloud.SoftlayerCredentials, error) {
+	return credentials.ReadIBMCloudFile(ibmCloudAuthFileName, credentials.Keys{})
+}
+
+// ParseVSphereCredentials reads the vCenter JSON auth file, an encrypted file is decrypted with the VCMD_* key variables
+func ParseVSphereCredentials(vcenterAuthFileName string) (map[string]vsphere.VCenterCredential, error) {
+	return credentials.ReadVSphereFile(vcenterAuthFileName, credentials.Keys{})
+}
 
-	b, err := os.ReadFile(ibmCloudAuthFileName)
+// vsphereCredentials returns the vCenter credentials of the provider chain, or of the auth file without one
+func vsphereCredentials(opts Options) (map[string]vsphere.VCenterCredential, error) {
+	var credentials map[string]vsphere.VCenterCredential
+	var err error
+	if opts.Credentials != nil {
+		credentials, err = opts.Credentials.VSphere(context.TODO())
+	} else {
+		credentials, err = ParseVSphereCredentials(opts.VCenterAuthFileName)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return settings(opts).ApplyVCenters(credentials), nil
+}
 
-	err = json.Unmarshal(b, &ibmCredentails)
-	if err != nil {
-		return nil, err
+// ibmCloudCredentials returns the IBM Cloud credentials of the provider chain, or of the auth file without one
+func ibmCloudCredentials(opts Options) (map[string]ibmcloud.SoftlayerCredentials, error) {
+	if opts.Credentials != nil {
+		return opts.Credentials.IBMCloud(context.TODO())
 	}
+	return ParseIBMCredentials(opts.IBMCloudAuthFileName)
+}
 
-	return ibmCredentails, nil
+// newVSphereMetadata creates the vSphere metadata with the cache and tag layout of the options
+func newVSphereMetadata(opts Options) *vsphere.Metadata {
+	vmeta := vsphere.NewMetadata()
+	vmeta.Cache = opts.Cache
+	vmeta.SessionCacheDir = opts.VSphereSessionCacheDir
+	vmeta.KeepAlive = opts.VSphereKeepAlive
+	if opts.TagLayout != nil {
+		vmeta.TagLayout = *opts.TagLayout
+	}
+	if opts.PortGroupNameSubstring != "" {
+		vmeta.PortGroupSubstring = opts.PortGroupNameSubstring
+	}
+	return vmeta
 }
-func parseVSphereCredentails(vcenterAuthFileName string) (map[string]vsphere.VCenterCredential, error) {
-	vCenterCredentails := make(map[string]vsphere.VCenterCredential)
 
-	b, err := os.ReadFile(vcenterAuthFileName)
+// NewIBMCloudMetadata creates the IBM Cloud metadata of the accounts in the auth file,
+// the accounts are returned sorted.
+func NewIBMCloudMetadata(opts Options) (*ibmcloud.Metadata, []string, error) {
+	imeta := ibmcloud.NewMetadata()
+	imeta.Cache = opts.Cache
+	if opts.PageSize > 0 {
+		imeta.PageSize = opts.PageSize
+	}
+
+	ibmCredentails, err := ibmCloudCredentials(opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	accounts := make([]string, 0, len(ibmCredentails))
+	for a, i := range ibmCredentails {
+		err := imeta.AddCredentials(a, i.Username, i.ApiToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		accounts = append(accounts, a)
 	}
+	sort.Strings(accounts)
 
-	err = json.Unmarshal(b, &vCenterCredentails)
+	return imeta, accounts, nil
+}
+
+// portGroupSubnets returns the distributed port groups of the server matching the substring by vlan id
+func portGroupSubnets(vmeta *vsphere.Metadata, server, portGroupNameSubstring string) (map[int32]PortGroupSubnet, error) {
+	portGroups, err := vmeta.GetDistributedPortGroups(server, portGroupNameSubstring)
 	if err != nil {
 		return nil, err
 	}
-	return vCenterCredentails, nil
+
+	portGroupSubnetsMap := make(map[int32]PortGroupSubnet)
+
+	for _, pg := range portGroups {
+		vlanId, ok := vsphere.PortGroupVlanId(pg)
+		if !ok {
+			log.Printf("WARNING: port group %s on %s is not on a single vlan", pg.Config.Name, server)
+			continue
+		}
+
+		portGroupSubnetsMap[vlanId] = PortGroupSubnet{
+			Name:   pg.Config.Name,
+			VlanId: vlanId,
+		}
+	}
+
+	return portGroupSubnetsMap, nil
 }
 
-func CreateVSphereEnvironmentsConfig(vCenterAuthFileName, ibmCloudAuthFileName, ipv6SubnetString, portGroupSubString string) ([]Asset, error) {
+func CreateVSphereEnvironmentsConfig(opts Options) ([]Asset, *RunReport, error) {
 	var envs VSphereEnvironmentsConfig
+	var reservations ibmcloud.Reservations
 	var assets = make([]Asset, 0)
+	var report = &RunReport{
+		VCenterLocations: make(map[string]VCenterLocationReport),
+		HostInventory:    make(map[string]PoolHostInventory),
+	}
 
-	vmeta := vsphere.NewMetadata()
-	imeta := ibmcloud.NewMetadata()
+	vmeta := newVSphereMetadata(opts)
+	defer vmeta.Logout()
+	s := settings(opts)
 
-	ibmCredentails, err := parseIBMCredentails(ibmCloudAuthFileName)
+	ipv6Allocator, err := newIPv6Allocator(opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	for a, i := range ibmCredentails {
-		err := imeta.AddCredentials(a, i.Username, i.ApiToken)
+	names, err := newNamer(s.Naming)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.ReservationsFileName != "" {
+		reservations, err = ibmcloud.ReadReservations(opts.ReservationsFileName)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	vcenterCredentials, err := parseVSphereCredentails(vCenterAuthFileName)
+	imeta, accounts, err := NewIBMCloudMetadata(opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	locator, err := newVCenterLocator(vmeta, imeta, accounts, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vcenterCredentials, err := vsphereCredentials(opts)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	for k, v := range vcenterCredentials {
-		_, err := vmeta.AddCredentials(k, v.Username, v.Password)
-		if err != nil {
-			log.Fatal(err)
+		if _, err := vmeta.AddVCenterCredential(k, v); err != nil {
+			return nil, nil, err
 		}
 
 		var dcPaths []string
 		datacenters, err := vmeta.GetDatacenters(k)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, dc := range datacenters {
+			if !s.Selection.Datacenters.Matches(dc.Name(), dc.InventoryPath) {
+				continue
+			}
 			dcPaths = append(dcPaths, dc.InventoryPath)
 		}
 
@@ -124,51 +281,57 @@ func CreateVSphereEnvironmentsConfig(vCenterAuthFileName, ibmCloudAuthFileName,
 			Datacenters: dcPaths,
 		})
 
-		portGroups, err := vmeta.GetDistributedPortGroups(k, portGroupSubString)
+		portGroupSubnetsMap, err := portGroupSubnets(vmeta, k, opts.PortGroupNameSubstring)
 		if err != nil {
-			return nil, err
-		}
-
-		portGroupSubnetsMap := make(map[int32]PortGroupSubnet)
-
-		for _, pg := range portGroups {
-			portSetting := pg.Config.DefaultPortConfig.(*types.VMwareDVSPortSetting)
-			vlanId := portSetting.Vlan.(*types.VmwareDistributedVirtualSwitchVlanIdSpec).VlanId
-
-			portGroupSubnetsMap[vlanId] = PortGroupSubnet{
-				Name:   pg.Config.Name,
-				VlanId: vlanId,
-			}
+			return nil, nil, err
 		}
 
 		url, err := vmeta.GetHostnameUrlVpxd(k)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if k != *url {
 			log.Printf("WARN: vCenter URL does not match %s != %s", k, *url)
 		}
 
-		vcIP, err := net.LookupIP(k)
+		vcLocation, err := locator.locate(k)
 		if err != nil {
-			log.Fatal(err)
+			return nil, nil, err
 		}
 
 		var networkVlans *[]datatypes.Network_Vlan
-		var vcLocation *ibmcloud.VCenterLocation
-		for account := range ibmCredentails {
-			vcLocation, err = imeta.FindVCenterPhyDC(account, vcIP)
-			if err != nil {
-				return nil, err
+		if vcLocation.DatacenterName != nil {
+			log.Printf("located vCenter %s in %s %s using the %s strategy", k, *vcLocation.DatacenterName, *vcLocation.PodName, vcLocation.Strategy)
+			report.VCenterLocations[k] = VCenterLocationReport{
+				Datacenter: *vcLocation.DatacenterName,
+				Pod:        *vcLocation.PodName,
+				Strategy:   vcLocation.Strategy,
 			}
 
-			if vcLocation.DatacenterName != nil {
+			for _, account := range s.accountsIn(accounts, *vcLocation.DatacenterName) {
 				networkVlans, err = imeta.GetVlanSubnets(account, *vcLocation.DatacenterName, *vcLocation.PodName)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
+				}
+				if len(*networkVlans) > 0 {
+					break
+				}
+			}
+		}
+
+		var hardware []datatypes.Hardware
+		if opts.HostInventory || opts.Costs {
+			datacenterName := ""
+			if vcLocation.DatacenterName != nil {
+				datacenterName = *vcLocation.DatacenterName
+			}
+			for _, account := range s.accountsIn(accounts, datacenterName) {
+				accountHardware, err := imeta.GetHardware(account, datacenterName)
+				if err != nil {
+					return nil, nil, err
 				}
-				break
+				hardware = append(hardware, accountHardware...)
 			}
 		}
 
@@ -181,16 +344,30 @@ func CreateVSphereEnvironmentsConfig(vCenterAuthFileName, ibmCloudAuthFileName,
 			}
 			continue
 		}
+		*failureDomains = s.selectFailureDomains(*failureDomains)
 
 		for _, fd := range *failureDomains {
 			cObj, err := vmeta.GetClusterByPath(fd.Server, fd.Topology.ComputeCluster)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
-			cpu, memory, err := vmeta.GetClusterCapacity(fd.Server, cObj)
-			if err != nil {
-				return nil, err
+			hostGroupZone, isHostGroup := vmeta.HostGroupZone(fd.Name)
+
+			var cpu int16
+			var memory int64
+			var hosts []vsphere.Host
+			if isHostGroup {
+				hosts, err = hostGroupHosts(vmeta, cObj, hostGroupZone)
+				if err != nil {
+					return nil, nil, err
+				}
+				cpu, memory = hostsCapacity(hosts)
+			} else {
+				cpu, memory, err = vmeta.GetClusterCapacity(fd.Server, cObj)
+				if err != nil {
+					return nil, nil, err
+				}
 			}
 
 			envs.FailureDomainsResourceCapacity = append(envs.FailureDomainsResourceCapacity, FailureDomainResourceCapacity{
@@ -199,41 +376,86 @@ func CreateVSphereEnvironmentsConfig(vCenterAuthFileName, ibmCloudAuthFileName,
 				TotalMemory: memory,
 			})
 
+			poolName, originalName, err := names.poolName(fd)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, err := names.register(vcmv1.PoolKind, poolName, originalName, fd.Server+"/"+fd.Name); err != nil {
+				return nil, nil, err
+			}
+
 			pool := vcmv1.Pool{
 				TypeMeta: metav1.TypeMeta{
 					Kind:       "Pool",
 					APIVersion: currentRunningGroupNameAndVersion,
 				},
 				ObjectMeta: metav1.ObjectMeta{
-					Name: strings.ToLower(fd.Name),
+					Name: poolName,
+					Annotations: map[string]string{
+						originalNameAnnotation:      originalName,
+						vsphereDatacenterAnnotation: fd.Topology.Datacenter,
+						vsphereClusterAnnotation:    fd.Topology.ComputeCluster,
+					},
 				},
 				Spec: vcmv1.PoolSpec{
 					VSpherePlatformFailureDomainSpec: fd,
 					VCpus:                            int(cpu),
 					Memory:                           int(memory / 1024 / 1024 / 1024),
 					Storage:                          0,
-					Exclude:                          true,
-					IBMPoolSpec: vcmv1.IBMPoolSpec{
-						Pod:        *vcLocation.PodName,
-						Datacenter: *vcLocation.DatacenterName,
-					},
-					NoSchedule: true,
+					Exclude:                          s.Capacity.Exclude,
+					NoSchedule:                       s.Capacity.NoSchedule,
 				},
 			}
 
+			if vcLocation.DatacenterName != nil {
+				pool.Spec.IBMPoolSpec = vcmv1.IBMPoolSpec{
+					Pod:        *vcLocation.PodName,
+					Datacenter: *vcLocation.DatacenterName,
+				}
+				pool.Annotations[locationStrategyAnnotation] = vcLocation.Strategy
+			}
+
+			if isHostGroup {
+				pool.Annotations[hostGroupAnnotation] = hostGroupZone.HostGroup
+				pool.Annotations[vmGroupAnnotation] = hostGroupZone.VMGroup
+			}
+
+			if opts.HostInventory || opts.Costs {
+				if !isHostGroup {
+					hosts, err = vmeta.GetClusterHosts(fd.Server, cObj)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				if opts.HostInventory {
+					report.HostInventory[pool.Name] = newPoolHostInventory(k, vcLocation, hosts, hardware)
+				}
+				if opts.Costs {
+					cost := newPoolCost(pool.Name, vcLocation, hosts, hardware)
+					cost.annotate(pool.Annotations)
+					report.Costs = append(report.Costs, cost)
+				}
+			}
+
+			s.Policy.Apply(&pool)
+
+			fileName, err := names.fileName(vcmv1.PoolKind, pool.Name)
+			if err != nil {
+				return nil, nil, err
+			}
 			assets = append(assets, Asset{
 				Asset:    pool,
-				FileName: fmt.Sprintf("pool-%s.yaml", pool.Name),
+				FileName: fileName,
 			})
 		}
 
 		envs.FailureDomains = append(envs.FailureDomains, *failureDomains...)
 
-		if networkVlans == nil {
-			if vcLocation != nil && vcLocation.PodName != nil {
-				log.Printf("WARNING: unable to retrieve IBM network subnets in datacenter pod %s vCenter %s using IP address %s", *vcLocation.PodName, k, vcIP[0].String())
+		if networkVlans == nil || len(*networkVlans) == 0 {
+			if vcLocation.PodName != nil {
+				log.Printf("WARNING: unable to retrieve IBM network subnets in datacenter pod %s vCenter %s", *vcLocation.PodName, k)
 			} else {
-				log.Printf("WARNING: unable to find physcial location of vCenter %s using IP address %s", k, vcIP[0].String())
+				log.Printf("WARNING: unable to find physcial location of vCenter %s using the %v location strategies", k, locator.strategies)
 			}
 			continue
 		}
@@ -241,84 +463,121 @@ func CreateVSphereEnvironmentsConfig(vCenterAuthFileName, ibmCloudAuthFileName,
 		for _, nv := range *networkVlans {
 			vlanNumber := int32(*nv.VlanNumber)
 
-			additionalSubnets := make([]datatypes.Network_Subnet, 0)
-			for account := range ibmCredentails {
-				taggedSubnets, err := imeta.GetSubnetsByTag(account, *vcLocation.DatacenterName, *vcLocation.PodName, fmt.Sprintf("pri_%d", vlanNumber))
-				if err != nil {
-					return nil, err
-				}
-
-				additionalSubnets = append(additionalSubnets, *taggedSubnets...)
+			vlanTags, err := ibmcloud.ParseTags(ibmcloud.TagNames(nv.TagReferences))
+			if err != nil {
+				log.Printf("WARNING: ignoring vlan %d with invalid tags: %v", vlanNumber, err)
+				continue
 			}
-
-			if len(additionalSubnets) > 1 {
-				log.Print("WARNING: the length of the additional subnets is greater then one")
+			if vlanTags.Exclude {
+				log.Printf("vlan %d is tagged %s", vlanNumber, ibmcloud.TagExclude)
+				continue
 			}
 
-			var ipv6NetworkSubnet *datatypes.Network_Subnet
-
-			// we only support a single ipv6 network subnet
-			for _, subnet := range additionalSubnets {
-				if *subnet.Version == 6 {
-					ipv6NetworkSubnet = &subnet
-					break
+			taggedSubnets := make([]ibmcloud.TaggedSubnet, 0)
+			for _, account := range s.accountsIn(accounts, *vcLocation.DatacenterName) {
+				accountSubnets, err := imeta.GetVlanTaggedSubnets(account, *vcLocation.DatacenterName, *vcLocation.PodName, *nv.VlanNumber)
+				if err != nil {
+					return nil, nil, err
 				}
+
+				taggedSubnets = append(taggedSubnets, accountSubnets...)
 			}
 
 			if pg, ok := portGroupSubnetsMap[vlanNumber]; ok {
-				if len(nv.Subnets) > 1 {
-					log.Print("WARNING: the length of the vlan subnet is greater then one, using only the first entry")
+				subnets, ipv6TaggedSubnets := vlanNetworkSubnets(nv, vlanTags, taggedSubnets, opts.SubnetTypes)
+				if len(subnets) == 0 {
+					log.Printf("WARNING: vlan %d has no IPv4 subnet of type %s", vlanNumber, strings.Join(opts.SubnetTypes, ","))
+					continue
 				}
 
-				subnet := nv.Subnets[0]
-
-				ipAddressesAsString := make([]string, 0, len(subnet.IpAddresses))
-				for _, ipAddress := range subnet.IpAddresses {
-					ipAddressesAsString = append(ipAddressesAsString, *ipAddress.IpAddress)
+				var networks []*vcmv1.Network
+				var networkSubnets []datatypes.Network_Subnet
+				for i, ns := range subnets {
+					// the first subnet keeps the original Network name, additional
+					// subnets on the vlan are suffixed with their CIDR.
+					nameData := NetworkNameData{
+						Server:     k,
+						PortGroup:  pg.Name,
+						VlanNumber: *nv.VlanNumber,
+						Datacenter: *nv.Datacenter.Name,
+						Pod:        *nv.PodName,
+					}
+					if i > 0 {
+						nameData.Subnet = subnetNameSuffix(ns.subnet)
+					}
+					name, originalName, err := names.networkName(nameData)
+					if err != nil {
+						return nil, nil, err
+					}
+
+					// the same vlan and subnet seen from another vCenter of the pod is generated once
+					registered, err := names.register(vcmv1.NetworkKind, name, originalName, fmt.Sprintf("vlan %d subnet %d", *nv.Id, *ns.subnet.Id))
+					if err != nil {
+						return nil, nil, err
+					}
+					if !registered {
+						log.Printf("Network %s of vlan %d was generated for another vCenter of pod %s, skipping", name, vlanNumber, *nv.PodName)
+						continue
+					}
+
+					network, err := newNetwork(name, pg, nv, ns.subnet, reservations, opts)
+					if errors.Is(err, errVIPAllocation) {
+						skipped := SkippedNetwork{
+							Name:   name,
+							Vlan:   *nv.VlanNumber,
+							Subnet: fmt.Sprintf("%s/%d", *ns.subnet.NetworkIdentifier, *ns.subnet.Cidr),
+							Reason: err.Error(),
+						}
+						log.Printf("WARNING: skipping Network %s of subnet %s vlan %d: %s", skipped.Name, skipped.Subnet, vlanNumber, skipped.Reason)
+						report.SkippedNetworks = append(report.SkippedNetworks, skipped)
+						continue
+					}
+					if err != nil {
+						return nil, nil, err
+					}
+					if network == nil {
+						continue
+					}
+					network.Annotations[originalNameAnnotation] = originalName
+					if ns.networkType != "" {
+						network.Labels = map[string]string{
+							vcmv1.NetworkTypeLabel: ns.networkType,
+						}
+					}
+					networks = append(networks, network)
+					networkSubnets = append(networkSubnets, ns.subnet)
 				}
 
-				ipv6Subnet := iplib.Net6FromStr(fmt.Sprintf("%s:%d::1/64", ipv6SubnetString, *nv.VlanNumber))
-				if ipv6NetworkSubnet != nil {
-					ipv6Subnet = iplib.Net6FromStr(fmt.Sprintf("%s/%d", *ipv6NetworkSubnet.Gateway, *ipv6NetworkSubnet.Cidr))
+				// the IPv6 subnet is only allocated for a vlan with a Network and only attached to
+				// its first Network, the Networks would otherwise hand out the same IPv6 addresses.
+				if len(networks) > 0 {
+					if ipv6Subnet := ipv6Allocator.allocate(nv, ipv6TaggedSubnets); ipv6Subnet != nil {
+						setIPv6Subnet(networks[0], ipv6Subnet)
+					} else {
+						log.Printf("WARNING: no IPv6 strategy produced a subnet for vlan %d", vlanNumber)
+					}
 				}
 
-				cidrV6, _ := ipv6Subnet.Mask().Size()
-
-				network := vcmv1.Network{
-					TypeMeta: metav1.TypeMeta{
-						Kind:       "Network",
-						APIVersion: currentRunningGroupNameAndVersion,
-					},
-					ObjectMeta: metav1.ObjectMeta{
-						Name: fmt.Sprintf("%s-%s-%s", pg.Name, *nv.Datacenter.Name, *nv.PodName),
-					},
-					Spec: vcmv1.NetworkSpec{
-						PortGroupName:         pg.Name,
-						VlanId:                strconv.Itoa(*nv.VlanNumber),
-						PodName:               nv.PodName,
-						DatacenterName:        nv.Datacenter.Name,
-						Cidr:                  subnet.Cidr,
-						Gateway:               subnet.Gateway,
-						IpAddressCount:        subnet.IpAddressCount,
-						Netmask:               subnet.Netmask,
-						SubnetType:            subnet.SubnetType,
-						MachineNetworkCidr:    fmt.Sprintf("%s/%d", *subnet.NetworkIdentifier, *subnet.Cidr),
-						IpAddresses:           ipAddressesAsString,
-						CidrIPv6:              cidrV6,
-						GatewayIPv6:           ipv6Subnet.Enumerate(1, 2)[0].String(),
-						IpV6prefix:            ipv6Subnet.String(),
-						StartIPv6Address:      ipv6Subnet.Enumerate(1, 4)[0].String(),
-						PrimaryRouterHostname: *nv.PrimaryRouter.Hostname,
-					},
+				for i, network := range networks {
+					if opts.Costs {
+						cost := newNetworkCost(network.Name, nv, networkSubnets[i], len(networks))
+						cost.annotate(network.Annotations)
+						report.Costs = append(report.Costs, cost)
+					}
+
+					fileName, err := names.fileName(vcmv1.NetworkKind, network.Name)
+					if err != nil {
+						return nil, nil, err
+					}
+					assets = append(assets, Asset{
+						Asset:    *network,
+						FileName: fileName,
+					})
 				}
-
-				assets = append(assets, Asset{
-					Asset:    network,
-					FileName: fmt.Sprintf("network-%s.yaml", network.Name),
-				})
 			}
 		}
 	}
 
-	return assets, nil
+	report.Cache = opts.Cache.Stats()
+	return assets, report, nil
 }
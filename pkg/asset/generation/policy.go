@@ -0,0 +1,179 @@
+package generation
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+)
+
+const policyAnnotation = "vspherecapacitymanager.splat.io/policy"
+
+const (
+	// PolicyResolutionFirst applies the first matching rule
+	PolicyResolutionFirst = "first"
+	// PolicyResolutionMostSpecific applies the matching rule with the most criteria, the first on a tie
+	PolicyResolutionMostSpecific = "most-specific"
+)
+
+// PolicyResolutions the ways of choosing among matching rules
+var PolicyResolutions = []string{PolicyResolutionFirst, PolicyResolutionMostSpecific}
+
+// Policy the rules setting the operator owned fields of the generated Pools
+type Policy struct {
+	Resolution string
+	Rules      []PolicyRule
+}
+
+// PolicyRule sets the fields of the Pools it matches, unset fields keep the capacity model's
+type PolicyRule struct {
+	Name        string
+	Match       PolicyMatch
+	Exclude     *bool
+	NoSchedule  *bool
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// PolicyMatch the criteria of a rule, all set criteria must match. The lists are glob patterns
+// of which one must match, datacenters and clusters match their name or inventory path.
+type PolicyMatch struct {
+	VCenters    []string
+	Datacenters []string
+	Clusters    []string
+	Regions     []string
+	Zones       []string
+	// Name a regular expression matched against the Pool name
+	Name string
+}
+
+// PolicyEvaluation the result of a rule for a Pool
+type PolicyEvaluation struct {
+	Rule        string
+	Matched     bool
+	Specificity int
+	// Reason the first criterion not matched
+	Reason string
+}
+
+// Validate checks the resolution, rule names, patterns and name expressions
+func (p Policy) Validate() error {
+	if p.Resolution != "" && p.Resolution != PolicyResolutionFirst && p.Resolution != PolicyResolutionMostSpecific {
+		return fmt.Errorf("unknown resolution %s, must be one of %s", p.Resolution, strings.Join(PolicyResolutions, ", "))
+	}
+
+	names := make(map[string]bool)
+	for i, r := range p.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rules[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rules[%d]: duplicate rule %s", i, r.Name)
+		}
+		names[r.Name] = true
+
+		m := r.Match
+		for _, patterns := range [][]string{m.VCenters, m.Datacenters, m.Clusters, m.Regions, m.Zones} {
+			if err := (Filter{Include: patterns}).Validate(); err != nil {
+				return fmt.Errorf("rule %s: %w", r.Name, err)
+			}
+		}
+		if m.Name != "" {
+			if _, err := regexp.Compile(m.Name); err != nil {
+				return fmt.Errorf("rule %s: invalid name expression: %w", r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the evaluation of every rule and the index of the applied rule, -1 if none matched
+func (p Policy) Evaluate(pool *vcmv1.Pool) ([]PolicyEvaluation, int) {
+	evaluations := make([]PolicyEvaluation, 0, len(p.Rules))
+	applied := -1
+	for i, r := range p.Rules {
+		e := r.evaluate(pool)
+		evaluations = append(evaluations, e)
+		if !e.Matched {
+			continue
+		}
+		switch {
+		case applied < 0:
+			applied = i
+		case p.Resolution == PolicyResolutionMostSpecific && e.Specificity > evaluations[applied].Specificity:
+			applied = i
+		}
+	}
+	return evaluations, applied
+}
+
+// Apply sets the fields of the applied rule on the Pool and records the rule in an annotation
+func (p Policy) Apply(pool *vcmv1.Pool) {
+	_, applied := p.Evaluate(pool)
+	if applied < 0 {
+		return
+	}
+	r := p.Rules[applied]
+
+	if r.Exclude != nil {
+		pool.Spec.Exclude = *r.Exclude
+	}
+	if r.NoSchedule != nil {
+		pool.Spec.NoSchedule = *r.NoSchedule
+	}
+	if len(r.Labels) > 0 && pool.Labels == nil {
+		pool.Labels = make(map[string]string)
+	}
+	for k, v := range r.Labels {
+		pool.Labels[k] = v
+	}
+	if pool.Annotations == nil {
+		pool.Annotations = make(map[string]string)
+	}
+	for k, v := range r.Annotations {
+		pool.Annotations[k] = v
+	}
+	pool.Annotations[policyAnnotation] = r.Name
+}
+
+func (r PolicyRule) evaluate(pool *vcmv1.Pool) PolicyEvaluation {
+	e := PolicyEvaluation{Rule: r.Name}
+	fd := pool.Spec.VSpherePlatformFailureDomainSpec
+	m := r.Match
+
+	for _, c := range []struct {
+		criterion string
+		patterns  []string
+		values    []string
+	}{
+		{"vCenter", m.VCenters, []string{fd.Server}},
+		{"datacenter", m.Datacenters, []string{path.Base(fd.Topology.Datacenter), fd.Topology.Datacenter}},
+		{"cluster", m.Clusters, []string{path.Base(fd.Topology.ComputeCluster), fd.Topology.ComputeCluster}},
+		{"region", m.Regions, []string{fd.Region}},
+		{"zone", m.Zones, []string{fd.Zone}},
+	} {
+		if len(c.patterns) == 0 {
+			continue
+		}
+		if !(Filter{Include: c.patterns}).Matches(c.values...) {
+			e.Reason = fmt.Sprintf("%s %s does not match %s", c.criterion, c.values[len(c.values)-1], strings.Join(c.patterns, ", "))
+			return e
+		}
+		e.Specificity++
+	}
+
+	if m.Name != "" {
+		// validated by Validate
+		re, err := regexp.Compile(m.Name)
+		if err != nil || !re.MatchString(pool.Name) {
+			e.Reason = fmt.Sprintf("name %s does not match %s", pool.Name, m.Name)
+			return e
+		}
+		e.Specificity++
+	}
+
+	e.Matched = true
+	return e
+}
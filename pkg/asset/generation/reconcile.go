@@ -0,0 +1,301 @@
+package generation
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/govmomi/vim25/mo"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	// FindingPortGroupWithoutVlan a port group has no IBM vlan in the pod of its vCenter
+	FindingPortGroupWithoutVlan = "port-group-without-vlan"
+	// FindingVlanWithoutPortGroup an IBM vlan in the pod of the vCenter has no port group of any name
+	FindingVlanWithoutPortGroup = "vlan-without-port-group"
+	// FindingVlanOnSeveralSwitches port groups of the same vlan are on several distributed switches
+	FindingVlanOnSeveralSwitches = "vlan-on-several-switches"
+	// FindingPortGroupMissingFromCluster a port group on a switch of the cluster's hosts is not available in
+	// the cluster of a failure domain
+	FindingPortGroupMissingFromCluster = "port-group-missing-from-cluster"
+)
+
+// ReconcileFinding a port group or IBM vlan that does not produce a Network as expected
+type ReconcileFinding struct {
+	Kind      string `json:"kind"`
+	Server    string `json:"server"`
+	VlanId    int32  `json:"vlanId"`
+	PortGroup string `json:"portGroup,omitempty"`
+	Detail    string `json:"detail"`
+
+	// VlanName and VlanTags the name and tags of the IBM vlan of a vlan-without-port-group finding
+	VlanName string   `json:"vlanName,omitempty"`
+	VlanTags []string `json:"vlanTags,omitempty"`
+}
+
+// VlanSelection selects IBM vlans by number, a substring of their name or one of their tags.
+// A vlan matching any of them is selected, nothing is selected without any.
+type VlanSelection struct {
+	Numbers       []int32
+	NameSubstring string
+	Tags          []string
+}
+
+// Empty reports if the selection has no criteria
+func (s VlanSelection) Empty() bool {
+	return len(s.Numbers) == 0 && s.NameSubstring == "" && len(s.Tags) == 0
+}
+
+// Matches reports if the IBM vlan of the finding is selected
+func (s VlanSelection) Matches(f ReconcileFinding) bool {
+	if slices.Contains(s.Numbers, f.VlanId) {
+		return true
+	}
+	if s.NameSubstring != "" && strings.Contains(f.VlanName, s.NameSubstring) {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if slices.Contains(f.VlanTags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ibmVlan the name and tags of an IBM vlan
+type ibmVlan struct {
+	fullyQualifiedName string
+	name               string
+	tags               []string
+}
+
+// ReconcileNetworks compares the port groups of every vCenter with the IBM vlans in its
+// pod and the clusters of its failure domains. The findings are sorted by kind, server and vlan.
+func ReconcileNetworks(opts Options) ([]ReconcileFinding, error) {
+	var findings []ReconcileFinding
+
+	vmeta := newVSphereMetadata(opts)
+	defer vmeta.Logout()
+
+	imeta, accounts, err := NewIBMCloudMetadata(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	locator, err := newVCenterLocator(vmeta, imeta, accounts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vcenterCredentials, err := vsphereCredentials(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range vcenterCredentials {
+		if _, err := vmeta.AddVCenterCredential(k, v); err != nil {
+			return nil, err
+		}
+
+		allPortGroups, err := vmeta.GetDistributedPortGroups(k, "")
+		if err != nil {
+			return nil, err
+		}
+
+		// a vlan with a port group of any name, e.g. of management or storage, is not missing one
+		vlansWithPortGroup := make(map[int32]bool)
+		var portGroups []mo.DistributedVirtualPortgroup
+		for _, pg := range allPortGroups {
+			if vlanId, ok := vsphere.PortGroupVlanId(pg); ok {
+				vlansWithPortGroup[vlanId] = true
+			}
+			if strings.Contains(pg.Config.Name, opts.PortGroupNameSubstring) {
+				portGroups = append(portGroups, pg)
+			}
+		}
+
+		switchNames, err := vmeta.GetDistributedSwitchNames(k)
+		if err != nil {
+			return nil, err
+		}
+
+		portGroupsByVlan := make(map[int32][]mo.DistributedVirtualPortgroup)
+		for _, pg := range portGroups {
+			vlanId, ok := vsphere.PortGroupVlanId(pg)
+			if !ok {
+				log.Printf("WARNING: port group %s on %s is not on a single vlan", pg.Config.Name, k)
+				continue
+			}
+			portGroupsByVlan[vlanId] = append(portGroupsByVlan[vlanId], pg)
+		}
+
+		for vlanId, pgs := range portGroupsByVlan {
+			switches := make(map[string]bool)
+			var described []string
+			for _, pg := range pgs {
+				name := ""
+				if pg.Config.DistributedVirtualSwitch != nil {
+					name = switchNames[pg.Config.DistributedVirtualSwitch.Value]
+				}
+				switches[name] = true
+				described = append(described, fmt.Sprintf("%s on %s", pg.Config.Name, name))
+			}
+			if len(switches) > 1 {
+				sort.Strings(described)
+				findings = append(findings, ReconcileFinding{
+					Kind:   FindingVlanOnSeveralSwitches,
+					Server: k,
+					VlanId: vlanId,
+					Detail: fmt.Sprintf("only one of %s produces a Network", strings.Join(described, ", ")),
+				})
+			}
+		}
+
+		vcLocation, err := locator.locate(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if vcLocation.DatacenterName == nil {
+			log.Printf("WARNING: unable to find physcial location of vCenter %s using the %v location strategies, skipping the IBM vlans", k, locator.strategies)
+		} else {
+			ibmVlans := make(map[int32]ibmVlan)
+			for _, account := range settings(opts).accountsIn(accounts, *vcLocation.DatacenterName) {
+				networkVlans, err := imeta.GetVlanSubnets(account, *vcLocation.DatacenterName, *vcLocation.PodName)
+				if err != nil {
+					return nil, err
+				}
+				for _, nv := range *networkVlans {
+					var v ibmVlan
+					if nv.FullyQualifiedName != nil {
+						v.fullyQualifiedName = *nv.FullyQualifiedName
+					}
+					if nv.Name != nil {
+						v.name = *nv.Name
+					}
+					v.tags = ibmcloud.TagNames(nv.TagReferences)
+					ibmVlans[int32(*nv.VlanNumber)] = v
+				}
+				if len(*networkVlans) > 0 {
+					break
+				}
+			}
+
+			for vlanId, pgs := range portGroupsByVlan {
+				if _, ok := ibmVlans[vlanId]; ok {
+					continue
+				}
+				for _, pg := range pgs {
+					findings = append(findings, ReconcileFinding{
+						Kind:      FindingPortGroupWithoutVlan,
+						Server:    k,
+						VlanId:    vlanId,
+						PortGroup: pg.Config.Name,
+						Detail:    fmt.Sprintf("no IBM vlan %d in %s %s", vlanId, *vcLocation.DatacenterName, *vcLocation.PodName),
+					})
+				}
+			}
+
+			for vlanId, v := range ibmVlans {
+				if vlansWithPortGroup[vlanId] {
+					continue
+				}
+				findings = append(findings, ReconcileFinding{
+					Kind:     FindingVlanWithoutPortGroup,
+					Server:   k,
+					VlanId:   vlanId,
+					Detail:   fmt.Sprintf("IBM vlan %s has no port group", v.fullyQualifiedName),
+					VlanName: v.name,
+					VlanTags: v.tags,
+				})
+			}
+		}
+
+		failureDomains, err := vmeta.GetFailureDomainsViaTag(k)
+		if failureDomains == nil {
+			if err != nil {
+				log.Printf("WARNING: No failure domains found for %s, %s", k, err)
+			}
+			continue
+		}
+
+		// host group failure domains share their cluster
+		clusterPaths := make(map[string]bool)
+		for _, fd := range settings(opts).selectFailureDomains(*failureDomains) {
+			if clusterPaths[fd.Topology.ComputeCluster] {
+				continue
+			}
+			clusterPaths[fd.Topology.ComputeCluster] = true
+
+			cObj, err := vmeta.GetClusterByPath(fd.Server, fd.Topology.ComputeCluster)
+			if err != nil {
+				return nil, err
+			}
+
+			networks, err := vmeta.GetClusterNetworks(fd.Server, cObj)
+			if err != nil {
+				return nil, err
+			}
+
+			// the port groups of switches, and datacenters, the cluster's hosts are not on are never available
+			clusterSwitches, err := vmeta.GetClusterSwitches(fd.Server, cObj)
+			if err != nil {
+				return nil, err
+			}
+
+			for vlanId, pgs := range portGroupsByVlan {
+				for _, pg := range pgs {
+					if networks[pg.Self.Value] {
+						continue
+					}
+					if pg.Config.DistributedVirtualSwitch == nil || !clusterSwitches[pg.Config.DistributedVirtualSwitch.Value] {
+						continue
+					}
+					findings = append(findings, ReconcileFinding{
+						Kind:      FindingPortGroupMissingFromCluster,
+						Server:    k,
+						VlanId:    vlanId,
+						PortGroup: pg.Config.Name,
+						Detail:    fmt.Sprintf("not available in cluster %s of failure domain %s", fd.Topology.ComputeCluster, fd.Name),
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		if a.VlanId != b.VlanId {
+			return a.VlanId < b.VlanId
+		}
+		if a.PortGroup != b.PortGroup {
+			return a.PortGroup < b.PortGroup
+		}
+		return a.Detail < b.Detail
+	})
+
+	return findings, nil
+}
+
+// WriteReconcileFindings writes the findings as a table
+func WriteReconcileFindings(w io.Writer, findings []ReconcileFinding) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tSERVER\tVLAN\tPORT GROUP\tDETAIL")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", f.Kind, f.Server, f.VlanId, f.PortGroup, f.Detail)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,37 @@
+package generation
+
+import "testing"
+
+func TestVlanSelection(t *testing.T) {
+	finding := ReconcileFinding{
+		Kind:     FindingVlanWithoutPortGroup,
+		VlanId:   1234,
+		VlanName: "ci-segment-1",
+		VlanTags: []string{"vcm:network-type=single-tenant", "ci"},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		selection VlanSelection
+		expected  bool
+	}{
+		{"nothing", VlanSelection{}, false},
+		{"number", VlanSelection{Numbers: []int32{1233, 1234}}, true},
+		{"other number", VlanSelection{Numbers: []int32{1235}}, false},
+		{"name", VlanSelection{NameSubstring: "ci-segment"}, true},
+		{"other name", VlanSelection{NameSubstring: "mgmt"}, false},
+		{"tag", VlanSelection{Tags: []string{"ci"}}, true},
+		{"tag substring", VlanSelection{Tags: []string{"c"}}, false},
+		{"any criteria", VlanSelection{Numbers: []int32{1235}, NameSubstring: "mgmt", Tags: []string{"ci"}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.selection.Matches(finding); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	if !(VlanSelection{}).Empty() || (VlanSelection{Tags: []string{"ci"}}).Empty() {
+		t.Error("expected only a selection without criteria to be empty")
+	}
+}
@@ -0,0 +1,73 @@
+package generation
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+)
+
+// VCenterLocationReport the IBM location of a vCenter and how it was found
+type VCenterLocationReport struct {
+	Datacenter string `json:"datacenter"`
+	Pod        string `json:"pod"`
+	Strategy   string `json:"strategy"`
+}
+
+// SkippedNetwork a Network that was not generated and why
+type SkippedNetwork struct {
+	Name   string `json:"name"`
+	Vlan   int    `json:"vlan"`
+	Subnet string `json:"subnet"`
+	Reason string `json:"reason"`
+}
+
+// RunReport summarises a generate run
+type RunReport struct {
+	// VCenterLocations contains the location of each located vCenter
+	VCenterLocations map[string]VCenterLocationReport `json:"vcenterLocations,omitempty"`
+
+	// HostInventory contains the hosts and bare metal servers of each Pool by Pool name
+	HostInventory map[string]PoolHostInventory `json:"hostInventory,omitempty"`
+
+	// Costs contains the monthly cost attributed to each Pool and Network
+	Costs []CostAttribution `json:"costs,omitempty"`
+
+	// SkippedNetworks contains the Networks of subnets that were not generated
+	SkippedNetworks []SkippedNetwork `json:"skippedNetworks,omitempty"`
+
+	// Cache contains the cache hits, misses and writes per kind
+	Cache map[cache.Kind]cache.Stats `json:"cache,omitempty"`
+}
+
+// Log writes the report summary to the log
+func (r *RunReport) Log() {
+	total := 0.0
+	for _, c := range r.Costs {
+		if c.Kind == CostKindPool {
+			total += c.MonthlyCost
+		}
+	}
+	if len(r.Costs) > 0 {
+		log.Printf("monthly cost of the Pool bare metal servers: %.2f", total)
+	}
+
+	if len(r.SkippedNetworks) > 0 {
+		log.Printf("WARNING: %d Networks were skipped, they are listed in the run report", len(r.SkippedNetworks))
+	}
+
+	for _, kind := range cache.Kinds(r.Cache) {
+		stats := r.Cache[kind]
+		log.Printf("cache %s: %d hits, %d misses, %d writes", kind, stats.Hits, stats.Misses, stats.Writes)
+	}
+}
+
+// WriteReport writes the report as json to fileName
+func WriteReport(r *RunReport, fileName string) error {
+	marshalled, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, marshalled, 0644)
+}
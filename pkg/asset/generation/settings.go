@@ -0,0 +1,158 @@
+package generation
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// Settings the per vCenter and account settings, selection, capacity model, policy and naming
+// templates of the configuration file
+type Settings struct {
+	VCenters  map[string]VCenterSettings
+	Accounts  map[string]AccountSettings
+	Selection Selection
+	Capacity  CapacityModel
+	Policy    Policy
+	Naming    NamingTemplates
+}
+
+// VCenterSettings the settings of a vCenter, merged into its credential and location
+type VCenterSettings struct {
+	CAFile     string
+	Thumbprint string
+	Location   *LocationOverride
+}
+
+// AccountSettings the settings of an IBM Cloud account
+type AccountSettings struct {
+	// Datacenters limits the IBM datacenters the account is searched in, empty searches every datacenter
+	Datacenters []string
+}
+
+// Selection the vCenters, datacenters and clusters included in the outputs
+type Selection struct {
+	VCenters    Filter
+	Datacenters Filter
+	Clusters    Filter
+}
+
+// Filter include and exclude glob patterns matched against names and inventory paths. Without
+// include patterns everything not excluded is included.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// CapacityModel the scheduling defaults of the generated Pools
+type CapacityModel struct {
+	Exclude    bool
+	NoSchedule bool
+}
+
+// DefaultCapacityModel generated Pools are excluded and unschedulable until they are reviewed
+func DefaultCapacityModel() CapacityModel {
+	return CapacityModel{Exclude: true, NoSchedule: true}
+}
+
+// DefaultSettings selects everything with the default capacity model and names
+func DefaultSettings() *Settings {
+	return &Settings{Capacity: DefaultCapacityModel(), Naming: DefaultNamingTemplates()}
+}
+
+// Validate checks the patterns are valid globs
+func (f Filter) Validate() error {
+	for _, p := range slices.Concat(f.Include, f.Exclude) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports if any of the values, e.g. the name and inventory path of an object, is
+// included and none is excluded
+func (f Filter) Matches(values ...string) bool {
+	for _, v := range values {
+		if matchAny(f.Exclude, v) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if matchAny(f.Include, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, v string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// settings returns the settings of the options, or the defaults
+func settings(opts Options) *Settings {
+	if opts.Settings != nil {
+		return opts.Settings
+	}
+	return DefaultSettings()
+}
+
+// ApplyVCenters removes the vCenters not selected and sets the CA file and thumbprint of the
+// vCenter settings on credentials without them
+func (s *Settings) ApplyVCenters(credentials map[string]vsphere.VCenterCredential) map[string]vsphere.VCenterCredential {
+	selected := make(map[string]vsphere.VCenterCredential, len(credentials))
+	for server, credential := range credentials {
+		if !s.Selection.VCenters.Matches(server) {
+			continue
+		}
+		if vs, ok := s.VCenters[server]; ok {
+			if credential.CAFile == "" {
+				credential.CAFile = vs.CAFile
+			}
+			if credential.Thumbprint == "" {
+				credential.Thumbprint = vs.Thumbprint
+			}
+		}
+		selected[server] = credential
+	}
+	return selected
+}
+
+// selectFailureDomains returns the failure domains of the selected datacenters and clusters
+func (s *Settings) selectFailureDomains(failureDomains []configv1.VSpherePlatformFailureDomainSpec) []configv1.VSpherePlatformFailureDomainSpec {
+	var selected []configv1.VSpherePlatformFailureDomainSpec
+	for _, fd := range failureDomains {
+		dc := fd.Topology.Datacenter
+		cluster := fd.Topology.ComputeCluster
+		if !s.Selection.Datacenters.Matches(path.Base(dc), dc) || !s.Selection.Clusters.Matches(path.Base(cluster), cluster) {
+			continue
+		}
+		selected = append(selected, fd)
+	}
+	return selected
+}
+
+// accountsIn returns the accounts searched in the IBM datacenter
+func (s *Settings) accountsIn(accounts []string, datacenter string) []string {
+	var in []string
+	for _, account := range accounts {
+		as, ok := s.Accounts[account]
+		if ok && len(as.Datacenters) > 0 && !slices.Contains(as.Datacenters, datacenter) {
+			continue
+		}
+		in = append(in, account)
+	}
+	return in
+}
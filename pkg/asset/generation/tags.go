@@ -0,0 +1,72 @@
+package generation
+
+import (
+	"log"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+)
+
+// networkSubnet an IPv4 subnet producing a Network and the network type it is labelled with
+type networkSubnet struct {
+	subnet      datatypes.Network_Subnet
+	networkType string
+}
+
+// vlanNetworkSubnets applies the vcm tags of the vlan, its subnets and the subnets tagged for it.
+// It returns the IPv4 subnets producing a Network, the vlan subnets of the subnet types first
+// followed by the IPv4 subnets tagged for the vlan, and the IPv6 subnets tagged for the vlan.
+func vlanNetworkSubnets(nv datatypes.Network_Vlan, vlanTags ibmcloud.Tags, tagged []ibmcloud.TaggedSubnet, subnetTypes []string) ([]networkSubnet, []datatypes.Network_Subnet) {
+	var ipv4 []networkSubnet
+	var ipv6 []datatypes.Network_Subnet
+
+	networkType := func(tags ibmcloud.Tags) string {
+		if tags.NetworkType != "" {
+			return tags.NetworkType
+		}
+		return vlanTags.NetworkType
+	}
+
+	seen := make(map[int]bool)
+	for _, s := range selectSubnets(nv.Subnets, subnetTypes) {
+		tags, err := ibmcloud.ParseTags(ibmcloud.TagNames(s.TagReferences))
+		if err != nil {
+			log.Printf("WARNING: ignoring subnet %s/%d of vlan %d with invalid tags: %v", *s.NetworkIdentifier, *s.Cidr, *nv.VlanNumber, err)
+			continue
+		}
+		if s.Id != nil {
+			seen[*s.Id] = true
+		}
+		if tags.Exclude {
+			log.Printf("subnet %s/%d of vlan %d is tagged %s", *s.NetworkIdentifier, *s.Cidr, *nv.VlanNumber, ibmcloud.TagExclude)
+			continue
+		}
+		ipv4 = append(ipv4, networkSubnet{subnet: s, networkType: networkType(tags)})
+	}
+
+	// tagged IPv4 subnets are added whatever their subnet type, tagging them is explicit
+	for _, s := range tagged {
+		if s.Tags.Exclude || (s.Id != nil && seen[*s.Id]) {
+			continue
+		}
+
+		role := ibmcloud.SubnetRole(s.Network_Subnet, s.Tags)
+		isIPv6 := s.Version != nil && *s.Version == 6
+		if (role == ibmcloud.SubnetRoleIPv6) != isIPv6 {
+			log.Printf("WARNING: ignoring subnet %d tagged for vlan %d, the %s role does not match the subnet version", *s.Id, *nv.VlanNumber, role)
+			continue
+		}
+
+		switch {
+		case role == ibmcloud.SubnetRoleIPv6:
+			ipv6 = append(ipv6, s.Network_Subnet)
+		case s.Tags.Legacy:
+			// pri_<vlan> only ever tagged the IPv6 subnet of a vlan
+		case s.NetworkIdentifier != nil && s.Cidr != nil:
+			ipv4 = append(ipv4, networkSubnet{subnet: s.Network_Subnet, networkType: networkType(s.Tags)})
+		}
+	}
+
+	return ipv4, ipv6
+}
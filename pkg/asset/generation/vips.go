@@ -0,0 +1,82 @@
+package generation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+)
+
+const (
+	vipsAnnotation             = "vspherecapacitymanager.splat.io/vips"
+	apiVIPAnnotationFormat     = "vspherecapacitymanager.splat.io/api-vip-%d"
+	ingressVIPAnnotationFormat = "vspherecapacitymanager.splat.io/ingress-vip-%d"
+)
+
+// VIPPair contains the API and Ingress VIPs reserved for a single cluster on a Network
+type VIPPair struct {
+	API     string `json:"api"`
+	Ingress string `json:"ingress"`
+}
+
+// allocateVIPs reserves VIP pairs from the top of the usable addresses, which
+// must be sorted in ascending order. Pair 0 is always the last two addresses,
+// pair 1 the two before that and so on, so the same range always yields the
+// same VIPs. The addresses that remain for nodes are returned.
+func allocateVIPs(usable []net.IP, pairs int) ([]VIPPair, []net.IP, error) {
+	if pairs <= 0 {
+		return nil, usable, nil
+	}
+	if len(usable) < pairs*2 {
+		return nil, usable, fmt.Errorf("%d usable ip addresses are not enough for %d VIP pairs", len(usable), pairs)
+	}
+
+	vips := make([]VIPPair, 0, pairs)
+	for i := 0; i < pairs; i++ {
+		top := len(usable) - i*2
+		vips = append(vips, VIPPair{
+			API:     usable[top-2].String(),
+			Ingress: usable[top-1].String(),
+		})
+	}
+
+	return vips, usable[:len(usable)-pairs*2], nil
+}
+
+// vipAnnotations returns the VIP pairs as Network annotations, both as a
+// single json document and as an api and ingress annotation per pair.
+func vipAnnotations(vips []VIPPair) (map[string]string, error) {
+	annotations := make(map[string]string)
+	if len(vips) == 0 {
+		return annotations, nil
+	}
+
+	marshalled, err := json.Marshal(vips)
+	if err != nil {
+		return nil, err
+	}
+	annotations[vipsAnnotation] = string(marshalled)
+
+	for i, vip := range vips {
+		annotations[fmt.Sprintf(apiVIPAnnotationFormat, i)] = vip.API
+		annotations[fmt.Sprintf(ingressVIPAnnotationFormat, i)] = vip.Ingress
+	}
+
+	return annotations, nil
+}
+
+// NetworkVIPs returns the VIP pairs recorded in the Network annotations
+func NetworkVIPs(network vcmv1.Network) ([]VIPPair, error) {
+	var vips []VIPPair
+
+	v, ok := network.Annotations[vipsAnnotation]
+	if !ok {
+		return vips, nil
+	}
+
+	if err := json.Unmarshal([]byte(v), &vips); err != nil {
+		return nil, fmt.Errorf("unable to parse %s annotation of network %s: %w", vipsAnnotation, network.Name, err)
+	}
+	return vips, nil
+}
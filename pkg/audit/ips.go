@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+	vcmv1 "github.com/openshift-splat-team/vsphere-capacity-manager/pkg/apis/vspherecapacitymanager.splat.io/v1"
+)
+
+const (
+	// ReasonInUse an address of the Network address pool or VIPs is used by a virtual machine
+	ReasonInUse = "in-use"
+	// ReasonOutsideSubnet a virtual machine uses an address outside the subnet of its port group
+	ReasonOutsideSubnet = "outside-subnet"
+)
+
+// Conflict a single ip address finding of the audit
+type Conflict struct {
+	Server         string `json:"server"`
+	VirtualMachine string `json:"virtualMachine"`
+	PortGroup      string `json:"portGroup"`
+	Network        string `json:"network,omitempty"`
+	IPAddress      string `json:"ipAddress"`
+	Reason         string `json:"reason"`
+}
+
+// IPConflicts cross-checks the guest ip addresses of every virtual machine on the server
+// against the Networks that belong to the pods of the server's Pools.
+func IPConflicts(vmeta *vsphere.Metadata, server string, pools []vcmv1.Pool, networks []vcmv1.Network) ([]Conflict, error) {
+	var conflicts []Conflict
+
+	// Networks do not reference a vCenter, the IBM pod and datacenter of the
+	// vCenter's Pools determine which Networks its port groups map to.
+	locations := make(map[string]bool)
+	for _, p := range pools {
+		if p.Spec.Server == server {
+			locations[p.Spec.IBMPoolSpec.Datacenter+"/"+p.Spec.IBMPoolSpec.Pod] = true
+		}
+	}
+
+	networksByPortGroup := make(map[string][]vcmv1.Network)
+	for _, n := range networks {
+		if n.Spec.DatacenterName == nil || n.Spec.PodName == nil {
+			continue
+		}
+		if locations[*n.Spec.DatacenterName+"/"+*n.Spec.PodName] {
+			networksByPortGroup[n.Spec.PortGroupName] = append(networksByPortGroup[n.Spec.PortGroupName], n)
+		}
+	}
+
+	guestAddresses, err := vmeta.GetGuestNetworkAddresses(server)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ga := range guestAddresses {
+		pgNetworks, ok := networksByPortGroup[ga.Network]
+		if !ok {
+			continue
+		}
+
+		for _, ip := range ga.IPAddresses {
+			var inSubnet bool
+			var inSubnetFamily bool
+
+			for _, n := range pgNetworks {
+				pool, err := addressPool(n)
+				if err != nil {
+					return nil, err
+				}
+				if pool[ip.String()] {
+					conflicts = append(conflicts, Conflict{
+						Server:         server,
+						VirtualMachine: ga.VirtualMachine,
+						PortGroup:      ga.Network,
+						Network:        n.Name,
+						IPAddress:      ip.String(),
+						Reason:         ReasonInUse,
+					})
+				}
+
+				for _, cidr := range []string{n.Spec.MachineNetworkCidr, n.Spec.IpV6prefix} {
+					_, ipNet, err := net.ParseCIDR(cidr)
+					if err != nil {
+						continue
+					}
+					if (ipNet.IP.To4() == nil) == (ip.To4() == nil) {
+						inSubnetFamily = true
+						if ipNet.Contains(ip) {
+							inSubnet = true
+						}
+					}
+				}
+			}
+
+			// only report addresses of a family the port group's Networks define
+			if inSubnetFamily && !inSubnet {
+				conflicts = append(conflicts, Conflict{
+					Server:         server,
+					VirtualMachine: ga.VirtualMachine,
+					PortGroup:      ga.Network,
+					IPAddress:      ip.String(),
+					Reason:         ReasonOutsideSubnet,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].Reason != conflicts[j].Reason {
+			return conflicts[i].Reason < conflicts[j].Reason
+		}
+		if conflicts[i].PortGroup != conflicts[j].PortGroup {
+			return conflicts[i].PortGroup < conflicts[j].PortGroup
+		}
+		return conflicts[i].VirtualMachine < conflicts[j].VirtualMachine
+	})
+
+	return conflicts, nil
+}
+
+// addressPool returns the node addresses and VIPs a Network hands out to clusters
+func addressPool(network vcmv1.Network) (map[string]bool, error) {
+	pool := make(map[string]bool)
+	for _, a := range network.Spec.IpAddresses {
+		if ip := net.ParseIP(a); ip != nil {
+			pool[ip.String()] = true
+		}
+	}
+
+	vips, err := generation.NetworkVIPs(network)
+	if err != nil {
+		return nil, err
+	}
+	for _, vip := range vips {
+		for _, a := range []string{vip.API, vip.Ingress} {
+			if ip := net.ParseIP(a); ip != nil {
+				pool[ip.String()] = true
+			}
+		}
+	}
+
+	return pool, nil
+}
+
+// WriteConflicts writes the conflicts as a table
+func WriteConflicts(w io.Writer, conflicts []Conflict) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REASON\tSERVER\tVIRTUAL MACHINE\tPORT GROUP\tNETWORK\tIP ADDRESS")
+	for _, c := range conflicts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Reason, c.Server, c.VirtualMachine, c.PortGroup, c.Network, c.IPAddress)
+	}
+	return tw.Flush()
+}
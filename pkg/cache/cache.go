@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind a type of cached lookup, each kind has its own TTL
+type Kind string
+
+const (
+	KindIBMVlans               Kind = "ibm-vlans"
+	KindIBMSubnets             Kind = "ibm-subnets"
+	KindIBMHardware            Kind = "ibm-hardware"
+	KindVSphereTagCategories   Kind = "vsphere-tag-categories"
+	KindVSphereTags            Kind = "vsphere-tags"
+	KindVSphereAttachedObjects Kind = "vsphere-attached-objects"
+)
+
+// DefaultTTLs are used for the kinds without a configured TTL
+var DefaultTTLs = map[Kind]time.Duration{
+	KindIBMVlans:               24 * time.Hour,
+	KindIBMSubnets:             24 * time.Hour,
+	KindIBMHardware:            24 * time.Hour,
+	KindVSphereTagCategories:   24 * time.Hour,
+	KindVSphereTags:            time.Hour,
+	KindVSphereAttachedObjects: time.Hour,
+}
+
+// Stats cache usage of a single kind
+type Stats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+	Writes int `json:"writes"`
+}
+
+type entry struct {
+	Key     string          `json:"key"`
+	Created time.Time       `json:"created"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// Cache persists lookups as json files below a directory. A nil Cache is valid,
+// it never hits and discards writes.
+type Cache struct {
+	dir     string
+	ttls    map[Kind]time.Duration
+	refresh bool
+
+	mu    sync.Mutex
+	stats map[Kind]*Stats
+}
+
+// New creates a cache in dir. The ttls override DefaultTTLs per kind, refresh
+// ignores the existing entries while still writing the new results.
+func New(dir string, ttls map[Kind]time.Duration, refresh bool) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:     dir,
+		ttls:    make(map[Kind]time.Duration),
+		refresh: refresh,
+		stats:   make(map[Kind]*Stats),
+	}
+	for k, v := range DefaultTTLs {
+		c.ttls[k] = v
+	}
+	for k, v := range ttls {
+		if _, ok := DefaultTTLs[k]; !ok {
+			return nil, fmt.Errorf("unknown cache kind %s", k)
+		}
+		c.ttls[k] = v
+	}
+
+	return c, nil
+}
+
+// ParseTTLs parses kind to duration strings, e.g. ibm-vlans=12h
+func ParseTTLs(ttls map[string]string) (map[Kind]time.Duration, error) {
+	parsed := make(map[Kind]time.Duration, len(ttls))
+	for k, v := range ttls {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL for cache kind %s: %w", k, err)
+		}
+		parsed[Kind(k)] = d
+	}
+	return parsed, nil
+}
+
+func (c *Cache) path(kind Kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, string(kind), hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) record(kind Kind, f func(s *Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.stats[kind]; !ok {
+		c.stats[kind] = &Stats{}
+	}
+	f(c.stats[kind])
+}
+
+// Get unmarshals the unexpired entry of the key into v and reports if there was one
+func (c *Cache) Get(kind Kind, key string, v any) bool {
+	if c == nil {
+		return false
+	}
+
+	hit := c.get(kind, key, v)
+	c.record(kind, func(s *Stats) {
+		if hit {
+			s.Hits++
+		} else {
+			s.Misses++
+		}
+	})
+	return hit
+}
+
+func (c *Cache) get(kind Kind, key string, v any) bool {
+	if c.refresh {
+		return false
+	}
+
+	b, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		return false
+	}
+
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil || e.Key != key {
+		return false
+	}
+	if time.Since(e.Created) > c.ttls[kind] {
+		return false
+	}
+
+	return json.Unmarshal(e.Value, v) == nil
+}
+
+// Put stores v as the entry of the key
+func (c *Cache) Put(kind Kind, key string, v any) error {
+	if c == nil {
+		return nil
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(entry{
+		Key:     key,
+		Created: time.Now(),
+		Value:   value,
+	})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	if err := writeFile(path, b); err != nil {
+		return err
+	}
+
+	c.record(kind, func(s *Stats) { s.Writes++ })
+	return nil
+}
+
+// writeFile writes a unique temporary file and renames it, so concurrent runs never read a
+// partial entry nor rename each other's
+func writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Stats returns the usage of each kind that was used
+func (c *Cache) Stats() map[Kind]Stats {
+	stats := make(map[Kind]Stats)
+	if c == nil {
+		return stats
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.stats {
+		stats[k] = *v
+	}
+	return stats
+}
+
+// Kinds returns the kinds in stats sorted by name
+func Kinds(stats map[Kind]Stats) []Kind {
+	kinds := make([]Kind, 0, len(stats))
+	for k := range stats {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
@@ -0,0 +1,505 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/asset/generation"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/credentials"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	// APIVersion the supported version of the configuration file
+	APIVersion = "vcmd.splat.io/v1"
+	// Kind the kind of the configuration file
+	Kind = "Config"
+
+	// DefaultFileName is loaded from the working directory when no configuration file is set
+	DefaultFileName = "vcmd.yaml"
+)
+
+// Config the declarative vcmd configuration, every field is optional and flags set on the
+// command line take precedence
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Credentials Credentials `json:"credentials,omitempty"`
+	VCenters    []VCenter   `json:"vcenters,omitempty"`
+	Accounts    []Account   `json:"accounts,omitempty"`
+	Selection   Selection   `json:"selection,omitempty"`
+	Networks    Networks    `json:"networks,omitempty"`
+	IPv6        IPv6        `json:"ipv6,omitempty"`
+	Capacity    Capacity    `json:"capacity,omitempty"`
+	Policy      Policy      `json:"policy,omitempty"`
+	Naming      Naming      `json:"naming,omitempty"`
+	Tags        Tags        `json:"tags,omitempty"`
+	Session     Session     `json:"session,omitempty"`
+	Cache       Cache       `json:"cache,omitempty"`
+	Output      Output      `json:"output,omitempty"`
+}
+
+// Credentials the credential providers and auth files
+type Credentials struct {
+	Providers      []string `json:"providers,omitempty"`
+	VCenterFile    string   `json:"vcenterFile,omitempty"`
+	IBMCloudFile   string   `json:"ibmcloudFile,omitempty"`
+	Helper         string   `json:"helper,omitempty"`
+	Secret         string   `json:"secret,omitempty"`
+	KeyFile        string   `json:"keyFile,omitempty"`
+	PassphraseFile string   `json:"passphraseFile,omitempty"`
+}
+
+// VCenter the settings of a vCenter
+type VCenter struct {
+	Server     string                       `json:"server"`
+	CAFile     string                       `json:"caFile,omitempty"`
+	Thumbprint string                       `json:"thumbprint,omitempty"`
+	Location   *generation.LocationOverride `json:"location,omitempty"`
+}
+
+// Account the settings of an IBM Cloud account
+type Account struct {
+	Name        string   `json:"name"`
+	Datacenters []string `json:"datacenters,omitempty"`
+}
+
+// Selection the include and exclude lists of vCenters, datacenters and clusters
+type Selection struct {
+	VCenters    Filter `json:"vcenters,omitempty"`
+	Datacenters Filter `json:"datacenters,omitempty"`
+	Clusters    Filter `json:"clusters,omitempty"`
+}
+
+// Filter glob patterns matched against names and inventory paths
+type Filter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Networks the network selection
+type Networks struct {
+	PortGroupSubstring    string   `json:"portGroupSubstring,omitempty"`
+	SubnetTypes           []string `json:"subnetTypes,omitempty"`
+	IPAddressCount        *int     `json:"ipAddressCount,omitempty"`
+	VIPPairs              *int     `json:"vipPairs,omitempty"`
+	ReservationsFile      string   `json:"reservationsFile,omitempty"`
+	LocationStrategies    []string `json:"locationStrategies,omitempty"`
+	LocationOverridesFile string   `json:"locationOverridesFile,omitempty"`
+	PageSize              int      `json:"pageSize,omitempty"`
+}
+
+// IPv6 the IPv6 strategy
+type IPv6 struct {
+	Subnet     string   `json:"subnet,omitempty"`
+	Strategies []string `json:"strategies,omitempty"`
+	StaticFile string   `json:"staticFile,omitempty"`
+}
+
+// Capacity the capacity model of the generated Pools
+type Capacity struct {
+	Exclude    *bool `json:"exclude,omitempty"`
+	NoSchedule *bool `json:"noSchedule,omitempty"`
+}
+
+// Policy the rules setting the operator owned fields of the generated Pools
+type Policy struct {
+	// Resolution first or most-specific, defaults to first
+	Resolution string       `json:"resolution,omitempty"`
+	Rules      []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule the criteria of a rule and the fields it sets
+type PolicyRule struct {
+	Name        string            `json:"name"`
+	Match       PolicyMatch       `json:"match,omitempty"`
+	Exclude     *bool             `json:"exclude,omitempty"`
+	NoSchedule  *bool             `json:"noSchedule,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PolicyMatch glob patterns of vCenters, datacenters, clusters, regions and zones, and a Pool name expression
+type PolicyMatch struct {
+	VCenters    []string `json:"vcenters,omitempty"`
+	Datacenters []string `json:"datacenters,omitempty"`
+	Clusters    []string `json:"clusters,omitempty"`
+	Regions     []string `json:"regions,omitempty"`
+	Zones       []string `json:"zones,omitempty"`
+	Name        string   `json:"name,omitempty"`
+}
+
+// Naming the Go templates of the Pool, Network and manifest file names, unset templates keep
+// the default names
+type Naming struct {
+	Pool        string `json:"pool,omitempty"`
+	Network     string `json:"network,omitempty"`
+	PoolFile    string `json:"poolFile,omitempty"`
+	NetworkFile string `json:"networkFile,omitempty"`
+	// MaxLength of the Pool and Network names, defaults to 253
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
+// Tags the region and zone tag layout
+type Tags struct {
+	RegionCategory string   `json:"regionCategory,omitempty"`
+	ZoneCategory   string   `json:"zoneCategory,omitempty"`
+	RegionTypes    []string `json:"regionTypes,omitempty"`
+	ZoneTypes      []string `json:"zoneTypes,omitempty"`
+}
+
+// Session the vCenter session cache and keep alive
+type Session struct {
+	CacheDir  string `json:"cacheDir,omitempty"`
+	KeepAlive string `json:"keepAlive,omitempty"`
+}
+
+// Cache the lookup cache
+type Cache struct {
+	Dir  string            `json:"dir,omitempty"`
+	TTLs map[string]string `json:"ttls,omitempty"`
+}
+
+// Output the manifest renderer and reports
+type Output struct {
+	Manifests     string `json:"manifests,omitempty"`
+	Format        string `json:"format,omitempty"`
+	Report        string `json:"report,omitempty"`
+	CostReport    string `json:"costReport,omitempty"`
+	HostInventory *bool  `json:"hostInventory,omitempty"`
+}
+
+// Load reads and validates the configuration file. Unknown fields are rejected, relative paths
+// are relative to the directory of the file.
+func Load(fileName string) (*Config, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s:\n%w", fileName, err)
+	}
+
+	c.resolvePaths(filepath.Dir(fileName))
+	return &c, nil
+}
+
+// Validate returns every schema violation of the configuration
+func (c *Config) Validate() error {
+	var errs []error
+	addf := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.APIVersion != APIVersion {
+		addf("apiVersion: unsupported version %q, must be %s", c.APIVersion, APIVersion)
+	}
+	if c.Kind != Kind {
+		addf("kind: must be %s", Kind)
+	}
+
+	for i, p := range c.Credentials.Providers {
+		if !slices.Contains(credentials.Providers, p) {
+			addf("credentials.providers[%d]: unknown provider %q, must be one of %s", i, p, strings.Join(credentials.Providers, ", "))
+		}
+	}
+
+	servers := make(map[string]bool)
+	for i, v := range c.VCenters {
+		if v.Server == "" {
+			addf("vcenters[%d].server: required", i)
+		} else if servers[v.Server] {
+			addf("vcenters[%d].server: duplicate vCenter %s", i, v.Server)
+		}
+		servers[v.Server] = true
+		if v.Thumbprint != "" {
+			if _, err := vsphere.NormalizeThumbprint(v.Thumbprint); err != nil {
+				addf("vcenters[%d].thumbprint: %v", i, err)
+			}
+		}
+		if v.Location != nil && (v.Location.Datacenter == "" || v.Location.Pod == "") {
+			addf("vcenters[%d].location: datacenter and pod are required", i)
+		}
+	}
+
+	accounts := make(map[string]bool)
+	for i, a := range c.Accounts {
+		if a.Name == "" {
+			addf("accounts[%d].name: required", i)
+		} else if accounts[a.Name] {
+			addf("accounts[%d].name: duplicate account %s", i, a.Name)
+		}
+		accounts[a.Name] = true
+	}
+
+	for _, f := range []struct {
+		name   string
+		filter Filter
+	}{
+		{"selection.vcenters", c.Selection.VCenters},
+		{"selection.datacenters", c.Selection.Datacenters},
+		{"selection.clusters", c.Selection.Clusters},
+	} {
+		if err := f.filter.filter().Validate(); err != nil {
+			addf("%s: %v", f.name, err)
+		}
+	}
+
+	if err := c.policy().Validate(); err != nil {
+		addf("policy: %v", err)
+	}
+
+	if err := c.naming().Validate(); err != nil {
+		addf("naming: %v", err)
+	}
+
+	if n := c.Networks.IPAddressCount; n != nil && *n < 0 {
+		addf("networks.ipAddressCount: must not be negative")
+	}
+	if n := c.Networks.VIPPairs; n != nil && *n < 0 {
+		addf("networks.vipPairs: must not be negative")
+	}
+	if c.Networks.PageSize < 0 {
+		addf("networks.pageSize: must not be negative")
+	}
+	for i, s := range c.Networks.LocationStrategies {
+		if !slices.Contains(generation.DefaultLocationStrategies, s) {
+			addf("networks.locationStrategies[%d]: unknown strategy %q, must be one of %s", i, s, strings.Join(generation.DefaultLocationStrategies, ", "))
+		}
+	}
+
+	ipv6Strategies := append(slices.Clone(generation.DefaultIPv6Strategies), string(generation.IPv6StrategyStatic))
+	for i, s := range c.IPv6.Strategies {
+		if !slices.Contains(ipv6Strategies, s) {
+			addf("ipv6.strategies[%d]: unknown strategy %q, must be one of %s", i, s, strings.Join(ipv6Strategies, ", "))
+		}
+	}
+	if slices.Contains(c.IPv6.Strategies, string(generation.IPv6StrategyStatic)) && c.IPv6.StaticFile == "" {
+		addf("ipv6.staticFile: required by the static strategy")
+	}
+
+	if _, err := c.tagLayout(); err != nil {
+		addf("tags: %v", err)
+	}
+
+	if c.Session.KeepAlive != "" {
+		if d, err := time.ParseDuration(c.Session.KeepAlive); err != nil || d < 0 {
+			addf("session.keepAlive: %q is not a duration, e.g. 5m", c.Session.KeepAlive)
+		}
+	}
+
+	if _, err := cache.ParseTTLs(c.Cache.TTLs); err != nil {
+		addf("cache.ttls: %v", err)
+	}
+
+	if c.Output.Format != "" && !slices.Contains(generation.ManifestFormats, c.Output.Format) {
+		addf("output.format: unknown format %q, must be one of %s", c.Output.Format, strings.Join(generation.ManifestFormats, ", "))
+	}
+
+	return errors.Join(errs...)
+}
+
+// tagLayout returns the tag layout, the unset fields default to the default layout's
+func (c *Config) tagLayout() (vsphere.TagLayout, error) {
+	layout := vsphere.DefaultTagLayout()
+	regionCategory, zoneCategory := layout.RegionCategory, layout.ZoneCategory
+	if c.Tags.RegionCategory != "" {
+		regionCategory = c.Tags.RegionCategory
+	}
+	if c.Tags.ZoneCategory != "" {
+		zoneCategory = c.Tags.ZoneCategory
+	}
+	regionTypes := c.Tags.RegionTypes
+	if len(regionTypes) == 0 {
+		regionTypes = []string{string(vsphere.RegionTypeDatacenter)}
+	}
+	zoneTypes := c.Tags.ZoneTypes
+	if len(zoneTypes) == 0 {
+		zoneTypes = []string{string(vsphere.ZoneTypeComputeCluster)}
+	}
+	return vsphere.NewTagLayout(regionCategory, zoneCategory, regionTypes, zoneTypes)
+}
+
+// resolvePaths makes the relative paths relative to the directory
+func (c *Config) resolvePaths(dir string) {
+	for _, p := range []*string{
+		&c.Credentials.VCenterFile,
+		&c.Credentials.IBMCloudFile,
+		&c.Credentials.KeyFile,
+		&c.Credentials.PassphraseFile,
+		&c.Networks.ReservationsFile,
+		&c.Networks.LocationOverridesFile,
+		&c.IPv6.StaticFile,
+		&c.Session.CacheDir,
+		&c.Cache.Dir,
+		&c.Output.Manifests,
+		&c.Output.Report,
+		&c.Output.CostReport,
+	} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for i := range c.VCenters {
+		if p := c.VCenters[i].CAFile; p != "" && !filepath.IsAbs(p) {
+			c.VCenters[i].CAFile = filepath.Join(dir, p)
+		}
+	}
+}
+
+// Flags returns the values of the configuration by the name of the flag they default
+func (c *Config) Flags() map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	setList := func(name string, values []string) {
+		set(name, strings.Join(values, ","))
+	}
+	setInt := func(name string, value *int) {
+		if value != nil {
+			set(name, strconv.Itoa(*value))
+		}
+	}
+
+	set("vcenter", c.Credentials.VCenterFile)
+	set("ibmcloud", c.Credentials.IBMCloudFile)
+	setList("credential-providers", c.Credentials.Providers)
+	set("credential-helper", c.Credentials.Helper)
+	set("credential-secret", c.Credentials.Secret)
+	set("key-file", c.Credentials.KeyFile)
+	set("passphrase-file", c.Credentials.PassphraseFile)
+
+	set("pg", c.Networks.PortGroupSubstring)
+	setList("subnet-types", c.Networks.SubnetTypes)
+	setInt("ip-count", c.Networks.IPAddressCount)
+	setInt("vip-pairs", c.Networks.VIPPairs)
+	set("reservations", c.Networks.ReservationsFile)
+	setList("location-strategies", c.Networks.LocationStrategies)
+	set("location-overrides", c.Networks.LocationOverridesFile)
+	if c.Networks.PageSize > 0 {
+		set("page-size", strconv.Itoa(c.Networks.PageSize))
+	}
+
+	set("subnet6", c.IPv6.Subnet)
+	setList("ipv6-strategies", c.IPv6.Strategies)
+	set("ipv6-static-file", c.IPv6.StaticFile)
+
+	set("region-category", c.Tags.RegionCategory)
+	set("zone-category", c.Tags.ZoneCategory)
+	setList("region-types", c.Tags.RegionTypes)
+	setList("zone-types", c.Tags.ZoneTypes)
+
+	set("session-cache-dir", c.Session.CacheDir)
+	set("keepalive", c.Session.KeepAlive)
+
+	set("cache-dir", c.Cache.Dir)
+	ttls := make([]string, 0, len(c.Cache.TTLs))
+	for kind, ttl := range c.Cache.TTLs {
+		ttls = append(ttls, kind+"="+ttl)
+	}
+	sort.Strings(ttls)
+	setList("cache-ttl", ttls)
+
+	set("manifests", c.Output.Manifests)
+	set("format", c.Output.Format)
+	set("report", c.Output.Report)
+	set("cost-report", c.Output.CostReport)
+	if c.Output.HostInventory != nil {
+		set("host-inventory", strconv.FormatBool(*c.Output.HostInventory))
+	}
+
+	return flags
+}
+
+// Settings returns the per vCenter and account settings, selection, capacity model, policy and
+// naming templates
+func (c *Config) Settings() *generation.Settings {
+	s := generation.DefaultSettings()
+
+	s.VCenters = make(map[string]generation.VCenterSettings, len(c.VCenters))
+	for _, v := range c.VCenters {
+		s.VCenters[v.Server] = generation.VCenterSettings{
+			CAFile:     v.CAFile,
+			Thumbprint: v.Thumbprint,
+			Location:   v.Location,
+		}
+	}
+
+	s.Accounts = make(map[string]generation.AccountSettings, len(c.Accounts))
+	for _, a := range c.Accounts {
+		s.Accounts[a.Name] = generation.AccountSettings{Datacenters: a.Datacenters}
+	}
+
+	s.Selection = generation.Selection{
+		VCenters:    c.Selection.VCenters.filter(),
+		Datacenters: c.Selection.Datacenters.filter(),
+		Clusters:    c.Selection.Clusters.filter(),
+	}
+
+	if c.Capacity.Exclude != nil {
+		s.Capacity.Exclude = *c.Capacity.Exclude
+	}
+	if c.Capacity.NoSchedule != nil {
+		s.Capacity.NoSchedule = *c.Capacity.NoSchedule
+	}
+
+	s.Policy = c.policy()
+	s.Naming = c.naming()
+
+	return s
+}
+
+func (c *Config) naming() generation.NamingTemplates {
+	return generation.NamingTemplates{
+		Pool:        c.Naming.Pool,
+		Network:     c.Naming.Network,
+		PoolFile:    c.Naming.PoolFile,
+		NetworkFile: c.Naming.NetworkFile,
+		MaxLength:   c.Naming.MaxLength,
+	}
+}
+
+func (c *Config) policy() generation.Policy {
+	p := generation.Policy{Resolution: c.Policy.Resolution}
+	for _, r := range c.Policy.Rules {
+		p.Rules = append(p.Rules, generation.PolicyRule{
+			Name: r.Name,
+			Match: generation.PolicyMatch{
+				VCenters:    r.Match.VCenters,
+				Datacenters: r.Match.Datacenters,
+				Clusters:    r.Match.Clusters,
+				Regions:     r.Match.Regions,
+				Zones:       r.Match.Zones,
+				Name:        r.Match.Name,
+			},
+			Exclude:     r.Exclude,
+			NoSchedule:  r.NoSchedule,
+			Labels:      r.Labels,
+			Annotations: r.Annotations,
+		})
+	}
+	return p
+}
+
+func (f Filter) filter() generation.Filter {
+	return generation.Filter{Include: f.Include, Exclude: f.Exclude}
+}
@@ -0,0 +1,238 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	// ProviderEnv reads the GOVC_*, SL_* and VCMD_* environment variables
+	ProviderEnv = "env"
+	// ProviderExec runs a credential helper printing JSON on stdout
+	ProviderExec = "exec"
+	// ProviderSecret reads a Kubernetes Secret
+	ProviderSecret = "secret"
+	// ProviderFile reads the vCenter and IBM Cloud JSON auth files
+	ProviderFile = "file"
+)
+
+// Providers the names of every provider
+var Providers = []string{ProviderEnv, ProviderExec, ProviderSecret, ProviderFile}
+
+// DefaultProviders reads the auth files only, the other providers are opted into so credentials in
+// the environment are never added to a run unasked
+var DefaultProviders = []string{ProviderFile}
+
+// Credentials the vCenter and IBM Cloud account credentials of a provider
+type Credentials struct {
+	VCenters map[string]vsphere.VCenterCredential     `json:"vcenters,omitempty"`
+	IBMCloud map[string]ibmcloud.SoftlayerCredentials `json:"ibmcloud,omitempty"`
+}
+
+// Provider resolves credentials, a provider without configuration returns none
+type Provider interface {
+	Name() string
+	Credentials(ctx context.Context) (*Credentials, error)
+}
+
+// Options the configuration of the providers
+type Options struct {
+	// Providers the provider names in chain order, defaults to DefaultProviders
+	Providers []string
+
+	VCenterAuthFileName  string
+	IBMCloudAuthFileName string
+
+	// HelperCommand the command line of the exec credential helper
+	HelperCommand string
+
+	// Secret the [namespace/]name of the Kubernetes Secret, the namespace defaults to the kubeconfig context's
+	Secret string
+
+	// Keys decrypt encrypted auth files
+	Keys Keys
+}
+
+// Chain resolves each vCenter and IBM Cloud account from the first provider that has it
+type Chain struct {
+	providers []Provider
+	resolved  *Credentials
+}
+
+// NewChain creates the provider chain of the options
+func NewChain(opts Options) (*Chain, error) {
+	names := opts.Providers
+	if len(names) == 0 {
+		names = DefaultProviders
+	}
+
+	c := &Chain{}
+	for _, name := range names {
+		switch name {
+		case ProviderEnv:
+			c.providers = append(c.providers, envProvider{})
+		case ProviderExec:
+			c.providers = append(c.providers, &execProvider{command: opts.HelperCommand})
+		case ProviderSecret:
+			c.providers = append(c.providers, &secretProvider{secret: opts.Secret})
+		case ProviderFile:
+			c.providers = append(c.providers, fileProvider{vcenterFileName: opts.VCenterAuthFileName, ibmCloudFileName: opts.IBMCloudAuthFileName, keys: opts.Keys})
+		default:
+			return nil, fmt.Errorf("unknown credential provider %s, must be one of %s", name, strings.Join(Providers, ", "))
+		}
+	}
+	return c, nil
+}
+
+// resolve merges the credentials of every provider once, the first provider with credentials for
+// a vCenter or account wins
+func (c *Chain) resolve(ctx context.Context) (*Credentials, error) {
+	if c.resolved != nil {
+		return c.resolved, nil
+	}
+
+	resolved := &Credentials{
+		VCenters: make(map[string]vsphere.VCenterCredential),
+		IBMCloud: make(map[string]ibmcloud.SoftlayerCredentials),
+	}
+
+	for _, p := range c.providers {
+		creds, err := p.Credentials(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("credential provider %s: %w", p.Name(), err)
+		}
+		if creds == nil {
+			continue
+		}
+		for server, v := range creds.VCenters {
+			if _, ok := resolved.VCenters[server]; !ok {
+				resolved.VCenters[server] = v
+			}
+		}
+		for account, v := range creds.IBMCloud {
+			if _, ok := resolved.IBMCloud[account]; !ok {
+				resolved.IBMCloud[account] = v
+			}
+		}
+	}
+
+	c.resolved = resolved
+	return resolved, nil
+}
+
+// VSphere returns the credentials of every vCenter
+func (c *Chain) VSphere(ctx context.Context) (map[string]vsphere.VCenterCredential, error) {
+	resolved, err := c.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved.VCenters) == 0 {
+		return nil, fmt.Errorf("no vCenter credentials found by the %s providers", c.providerNames())
+	}
+	return resolved.VCenters, nil
+}
+
+// IBMCloud returns the credentials of every IBM Cloud account
+func (c *Chain) IBMCloud(ctx context.Context) (map[string]ibmcloud.SoftlayerCredentials, error) {
+	resolved, err := c.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved.IBMCloud) == 0 {
+		return nil, fmt.Errorf("no IBM Cloud credentials found by the %s providers", c.providerNames())
+	}
+	return resolved.IBMCloud, nil
+}
+
+func (c *Chain) providerNames() string {
+	names := make([]string, 0, len(c.providers))
+	for _, p := range c.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// fileProvider reads the JSON auth files, a missing file provides no credentials
+type fileProvider struct {
+	vcenterFileName  string
+	ibmCloudFileName string
+	keys             Keys
+}
+
+func (fileProvider) Name() string { return ProviderFile }
+
+func (p fileProvider) Credentials(ctx context.Context) (*Credentials, error) {
+	creds := &Credentials{}
+
+	if p.vcenterFileName != "" {
+		vcenters, err := ReadVSphereFile(p.vcenterFileName, p.keys)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		creds.VCenters = vcenters
+	}
+
+	if p.ibmCloudFileName != "" {
+		accounts, err := ReadIBMCloudFile(p.ibmCloudFileName, p.keys)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		creds.IBMCloud = accounts
+	}
+
+	return creds, nil
+}
+
+// ReadVSphereFile reads the vCenter JSON auth file, decrypting it with the keys when encrypted
+func ReadVSphereFile(name string, keys Keys) (map[string]vsphere.VCenterCredential, error) {
+	vcenters := make(map[string]vsphere.VCenterCredential)
+	if err := readJSONFile(name, keys, &vcenters); err != nil {
+		return nil, err
+	}
+	return vcenters, nil
+}
+
+// ReadIBMCloudFile reads the IBM Cloud JSON auth file, decrypting it with the keys when encrypted
+func ReadIBMCloudFile(name string, keys Keys) (map[string]ibmcloud.SoftlayerCredentials, error) {
+	accounts := make(map[string]ibmcloud.SoftlayerCredentials)
+	if err := readJSONFile(name, keys, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ReadFile reads a credential file, decrypting it in memory when encrypted
+func ReadFile(name string, keys Keys) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !IsEncrypted(b) {
+		return b, nil
+	}
+	plaintext, err := Decrypt(b, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+func readJSONFile(name string, keys Keys, v any) error {
+	b, err := ReadFile(name, keys)
+	if err != nil {
+		return err
+	}
+	defer clear(b)
+	// the syntax errors hold offsets only, never the content
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+	return nil
+}
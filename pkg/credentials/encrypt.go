@@ -0,0 +1,364 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/term"
+)
+
+const (
+	// EncryptedFormat identifies an encrypted credential file
+	EncryptedFormat = "vcmd-encrypted/v1"
+
+	// EnvPassphrase and EnvKeyFile are read when no passphrase file or key file is set
+	EnvPassphrase = "VCMD_PASSPHRASE"
+	EnvKeyFile    = "VCMD_KEY_FILE"
+
+	cipherAESGCM   = "aes-256-gcm"
+	kdfPBKDF2      = "pbkdf2-sha256"
+	kdfKeyFile     = "key-file"
+	kdfIterations  = 600000
+	keySize        = 32
+	saltSize       = 16
+	minPassphrase  = 12
+	passphraseHint = "set --passphrase-file, " + EnvPassphrase + " or run in a terminal"
+)
+
+// Keys the passphrase or key file encrypting the credential files. A key file holds 32 bytes,
+// raw, hex or base64 encoded, and takes precedence over a passphrase when encrypting.
+type Keys struct {
+	KeyFile        string
+	PassphraseFile string
+}
+
+// encryptedFile the envelope of an encrypted credential file, the parameters are authenticated
+type encryptedFile struct {
+	Format     string `json:"format"`
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// IsEncrypted reports if the file content is an encrypted credential file
+func IsEncrypted(data []byte) bool {
+	_, ok := parseEncrypted(data)
+	return ok
+}
+
+func parseEncrypted(data []byte) (*encryptedFile, bool) {
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Format != EncryptedFormat {
+		return nil, false
+	}
+	return &f, true
+}
+
+// Encrypt encrypts the plaintext with the key file, or a key derived from the passphrase
+func Encrypt(plaintext []byte, keys Keys) ([]byte, error) {
+	f := &encryptedFile{Format: EncryptedFormat, Cipher: cipherAESGCM}
+
+	var key []byte
+	if keyFile := keys.keyFile(); keyFile != "" {
+		var err error
+		if key, err = readKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+		f.KDF = kdfKeyFile
+	} else {
+		passphrase, err := keys.passphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		f.KDF = kdfPBKDF2
+		f.Iterations = kdfIterations
+		f.Salt = make([]byte, saltSize)
+		if _, err := rand.Read(f.Salt); err != nil {
+			return nil, err
+		}
+		key = pbkdf2SHA256(passphrase, f.Salt, f.Iterations, keySize)
+		clear(passphrase)
+	}
+	defer clear(key)
+
+	return f.seal(key, plaintext)
+}
+
+// Decrypt decrypts an encrypted credential file with the key file or passphrase it was encrypted with
+func Decrypt(data []byte, keys Keys) ([]byte, error) {
+	f, ok := parseEncrypted(data)
+	if !ok {
+		return nil, errors.New("not an encrypted credential file")
+	}
+	key, err := f.key(keys)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	return f.open(key)
+}
+
+// key reads the key file, or derives the key from the passphrase, of the file's parameters
+func (f *encryptedFile) key(keys Keys) ([]byte, error) {
+	if f.Cipher != cipherAESGCM {
+		return nil, fmt.Errorf("unsupported cipher %s", f.Cipher)
+	}
+
+	switch f.KDF {
+	case kdfKeyFile:
+		keyFile := keys.keyFile()
+		if keyFile == "" {
+			return nil, fmt.Errorf("the file is encrypted with a key file, set --key-file or %s", EnvKeyFile)
+		}
+		return readKeyFile(keyFile)
+	case kdfPBKDF2:
+		if f.Iterations < 1 || len(f.Salt) == 0 {
+			return nil, errors.New("invalid key derivation parameters")
+		}
+		passphrase, err := keys.passphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(passphrase)
+		return pbkdf2SHA256(passphrase, f.Salt, f.Iterations, keySize), nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %s", f.KDF)
+	}
+}
+
+// seal encrypts the plaintext with a new nonce and returns the file
+func (f *encryptedFile) seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return nil, err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plaintext, f.additionalData())
+
+	return json.MarshalIndent(f, "", "  ")
+}
+
+func (f *encryptedFile) open(key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, f.additionalData())
+	if err != nil {
+		return nil, errors.New("unable to decrypt, wrong passphrase or key file, or the file was modified")
+	}
+	return plaintext, nil
+}
+
+// additionalData binds the cipher and key derivation parameters to the ciphertext
+func (f *encryptedFile) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s|%s|%s|%d|%x", f.Format, f.Cipher, f.KDF, f.Iterations, f.Salt))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k Keys) keyFile() string {
+	if k.KeyFile != "" {
+		return k.KeyFile
+	}
+	return os.Getenv(EnvKeyFile)
+}
+
+// passphrase reads the passphrase file, the environment, or prompts on the terminal, confirming
+// a new passphrase
+func (k Keys) passphrase(confirm bool) ([]byte, error) {
+	var passphrase []byte
+	switch {
+	case k.PassphraseFile != "":
+		b, err := os.ReadFile(k.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the passphrase file: %w", err)
+		}
+		passphrase = bytes.TrimRight(b, "\r\n")
+	case os.Getenv(EnvPassphrase) != "":
+		passphrase = []byte(os.Getenv(EnvPassphrase))
+	default:
+		p, err := promptSecret("Passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if confirm {
+			again, err := promptSecret("Confirm passphrase: ")
+			if err != nil {
+				return nil, err
+			}
+			same := bytes.Equal(p, again)
+			clear(again)
+			if !same {
+				clear(p)
+				return nil, errors.New("the passphrases do not match")
+			}
+		}
+		passphrase = p
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.New("the passphrase is empty")
+	}
+	if confirm && len(passphrase) < minPassphrase {
+		return nil, fmt.Errorf("the passphrase must have at least %d characters", minPassphrase)
+	}
+	return passphrase, nil
+}
+
+// promptSecret reads a secret from the terminal without echo
+func promptSecret(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no passphrase, %s", passphraseHint)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return b, err
+}
+
+// readKeyFile reads a 32 byte key, raw or hex or base64 encoded
+func readKeyFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the key file: %w", err)
+	}
+	if len(b) == keySize {
+		return b, nil
+	}
+	defer clear(b)
+
+	text := strings.TrimSpace(string(b))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("the key file %s must hold %d bytes, raw, hex or base64 encoded", name, keySize)
+}
+
+// pbkdf2SHA256 derives a key from the password as in RFC 8018 with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	return pbkdf2.Key(password, salt, iterations, keyLen, sha256.New)
+}
+
+// EncryptFile encrypts a plaintext credential file to the output, which may be the file itself
+func EncryptFile(name, output string, keys Keys) error {
+	plaintext, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	defer clear(plaintext)
+
+	if IsEncrypted(plaintext) {
+		return fmt.Errorf("%s is already encrypted", name)
+	}
+	if !json.Valid(plaintext) {
+		return fmt.Errorf("%s is not a JSON credential file", name)
+	}
+
+	data, err := Encrypt(plaintext, keys)
+	if err != nil {
+		return err
+	}
+	return writeFile(output, data)
+}
+
+// EditFile decrypts the credential file in memory, applies the edit to its entries, and encrypts
+// it again with the same key and a new nonce. Plaintext files are refused, encrypt them first.
+func EditFile(name string, keys Keys, edit func(entries map[string]map[string]any) error) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	f, ok := parseEncrypted(data)
+	if !ok {
+		return fmt.Errorf("%s is not encrypted, encrypt it first", name)
+	}
+
+	key, err := f.key(keys)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+
+	plaintext, err := f.open(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	entries := make(map[string]map[string]any)
+	err = json.Unmarshal(plaintext, &entries)
+	clear(plaintext)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+
+	if err := edit(entries); err != nil {
+		return err
+	}
+
+	plaintext, err = json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	plaintext = append(plaintext, '\n')
+	defer clear(plaintext)
+
+	data, err = f.seal(key, plaintext)
+	if err != nil {
+		return err
+	}
+	return writeFile(name, data)
+}
+
+// writeFile replaces the file atomically, readable only by the user
+func writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,269 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const plaintextAuthFile = `{"vcenter.example.com":{"Username":"user","Password":"secret"}}`
+
+// writeTestFile writes the data to a file of the test's temporary directory
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// testKeys returns keys of a random key file and of a passphrase file, unset in the environment
+func testKeys(t *testing.T) (Keys, Keys) {
+	t.Helper()
+	t.Setenv(EnvKeyFile, "")
+	t.Setenv(EnvPassphrase, "")
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return Keys{KeyFile: writeTestFile(t, "key", key)},
+		Keys{PassphraseFile: writeTestFile(t, "passphrase", []byte("correct horse battery staple\n"))}
+}
+
+func parseTestFile(t *testing.T, data []byte) *encryptedFile {
+	t.Helper()
+	f, ok := parseEncrypted(data)
+	if !ok {
+		t.Fatalf("not an encrypted file: %s", data)
+	}
+	return f
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyFileKeys, passphraseKeys := testKeys(t)
+
+	key, err := os.ReadFile(keyFileKeys.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hexKeys := Keys{KeyFile: writeTestFile(t, "key.hex", []byte(hex.EncodeToString(key)+"\n"))}
+	base64Keys := Keys{KeyFile: writeTestFile(t, "key.b64", []byte(base64.StdEncoding.EncodeToString(key)))}
+
+	for _, tc := range []struct {
+		name    string
+		encrypt Keys
+		decrypt Keys
+		kdf     string
+	}{
+		{"key file", keyFileKeys, keyFileKeys, kdfKeyFile},
+		{"hex key file", hexKeys, keyFileKeys, kdfKeyFile},
+		{"base64 key file", keyFileKeys, base64Keys, kdfKeyFile},
+		{"passphrase", passphraseKeys, passphraseKeys, kdfPBKDF2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Encrypt([]byte(plaintextAuthFile), tc.encrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("secret")) {
+				t.Fatal("the encrypted file holds the plaintext")
+			}
+			if !IsEncrypted(data) {
+				t.Fatal("the encrypted file is not recognised")
+			}
+
+			f := parseTestFile(t, data)
+			if f.Cipher != cipherAESGCM || f.KDF != tc.kdf {
+				t.Errorf("expected %s with %s, got %s with %s", cipherAESGCM, tc.kdf, f.Cipher, f.KDF)
+			}
+			if tc.kdf == kdfPBKDF2 && (f.Iterations != kdfIterations || len(f.Salt) != saltSize) {
+				t.Errorf("expected %d iterations and a %d byte salt, got %d and %d", kdfIterations, saltSize, f.Iterations, len(f.Salt))
+			}
+
+			plaintext, err := Decrypt(data, tc.decrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(plaintext) != plaintextAuthFile {
+				t.Errorf("expected %s, got %s", plaintextAuthFile, plaintext)
+			}
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		data, err := Encrypt([]byte(plaintextAuthFile), keyFileKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherKeys, _ := testKeys(t)
+		if _, err := Decrypt(data, otherKeys); err == nil {
+			t.Error("expected the decryption with another key to fail")
+		}
+		if _, err := Decrypt(data, passphraseKeys); err == nil || !strings.Contains(err.Error(), "key file") {
+			t.Errorf("expected a key file to be required, got %v", err)
+		}
+	})
+
+	t.Run("short passphrase", func(t *testing.T) {
+		short := Keys{PassphraseFile: writeTestFile(t, "short", []byte("short"))}
+		if _, err := Encrypt([]byte(plaintextAuthFile), short); err == nil {
+			t.Error("expected a short passphrase to be refused")
+		}
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		if IsEncrypted([]byte(plaintextAuthFile)) {
+			t.Error("a plaintext auth file is recognised as encrypted")
+		}
+		if _, err := Decrypt([]byte(plaintextAuthFile), keyFileKeys); err == nil {
+			t.Error("expected decrypting a plaintext file to fail")
+		}
+	})
+}
+
+func TestDecryptTampered(t *testing.T) {
+	keyFileKeys, passphraseKeys := testKeys(t)
+
+	keyFileData, err := Encrypt([]byte(plaintextAuthFile), keyFileKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphraseData, err := Encrypt([]byte(plaintextAuthFile), passphraseKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		keys   Keys
+		tamper func(f *encryptedFile)
+	}{
+		{"ciphertext", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Ciphertext[0] ^= 1 }},
+		{"truncated ciphertext", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Ciphertext = f.Ciphertext[:len(f.Ciphertext)-1] }},
+		{"nonce", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Nonce[0] ^= 1 }},
+		{"short nonce", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Nonce = f.Nonce[1:] }},
+		{"iterations", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Iterations = 1 }},
+		{"salt", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Salt = []byte("salt") }},
+		{"cipher", keyFileData, keyFileKeys, func(f *encryptedFile) { f.Cipher = "aes-128-gcm" }},
+		{"kdf", keyFileData, keyFileKeys, func(f *encryptedFile) { f.KDF = "scrypt" }},
+		{"passphrase iterations", passphraseData, passphraseKeys, func(f *encryptedFile) { f.Iterations-- }},
+		{"passphrase salt", passphraseData, passphraseKeys, func(f *encryptedFile) { f.Salt[0] ^= 1 }},
+		{"passphrase ciphertext", passphraseData, passphraseKeys, func(f *encryptedFile) { f.Ciphertext[len(f.Ciphertext)-1] ^= 1 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := parseTestFile(t, tc.data)
+			tc.tamper(f)
+			tampered, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plaintext, err := Decrypt(tampered, tc.keys)
+			if err == nil {
+				t.Fatalf("expected the tampered file to be rejected, decrypted %s", plaintext)
+			}
+		})
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	_, passphraseKeys := testKeys(t)
+
+	data, err := Encrypt([]byte(plaintextAuthFile), passphraseKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := writeTestFile(t, "vcenter.json", data)
+	before := parseTestFile(t, data)
+
+	err = EditFile(fileName, passphraseKeys, func(entries map[string]map[string]any) error {
+		entries["vcenter.example.com"]["Password"] = "rotated"
+		entries["vcenter2.example.com"] = map[string]any{"Username": "user2", "Password": "secret2"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	after := parseTestFile(t, edited)
+	if after.Cipher != before.Cipher || after.KDF != before.KDF || after.Iterations != before.Iterations || !bytes.Equal(after.Salt, before.Salt) {
+		t.Errorf("expected the key derivation settings to be kept, got %s %s %d %x, was %s %s %d %x",
+			after.Cipher, after.KDF, after.Iterations, after.Salt, before.Cipher, before.KDF, before.Iterations, before.Salt)
+	}
+	if bytes.Equal(after.Nonce, before.Nonce) {
+		t.Error("expected a new nonce")
+	}
+
+	vcenters, err := ReadVSphereFile(fileName, passphraseKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vcenters) != 2 || vcenters["vcenter.example.com"].Password != "rotated" || vcenters["vcenter2.example.com"].Username != "user2" {
+		t.Errorf("unexpected edited credentials %+v", vcenters)
+	}
+
+	t.Run("plaintext", func(t *testing.T) {
+		plaintextFile := writeTestFile(t, "plain.json", []byte(plaintextAuthFile))
+		err := EditFile(plaintextFile, passphraseKeys, func(map[string]map[string]any) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "not encrypted") {
+			t.Errorf("expected a plaintext file to be refused, got %v", err)
+		}
+	})
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11, the keys of existing encrypted files must not change
+	for _, tc := range []struct {
+		password   string
+		salt       string
+		iterations int
+		expected   string
+	}{
+		{
+			password:   "passwd",
+			salt:       "salt",
+			iterations: 1,
+			expected: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		},
+		{
+			password:   "Password",
+			salt:       "NaCl",
+			iterations: 80000,
+			expected: "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+				"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d",
+		},
+	} {
+		t.Run(tc.password, func(t *testing.T) {
+			key := pbkdf2SHA256([]byte(tc.password), []byte(tc.salt), tc.iterations, 64)
+			if got := hex.EncodeToString(key); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+			// a shorter key is a prefix of the longer one
+			if got := hex.EncodeToString(pbkdf2SHA256([]byte(tc.password), []byte(tc.salt), tc.iterations, keySize)); got != tc.expected[:2*keySize] {
+				t.Errorf("expected %s, got %s", tc.expected[:2*keySize], got)
+			}
+		})
+	}
+}
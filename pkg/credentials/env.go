@@ -0,0 +1,112 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	// EnvVCenters and EnvIBMCloud hold the JSON of the vCenter and IBM Cloud auth files
+	EnvVCenters = "VCMD_VCENTERS"
+	EnvIBMCloud = "VCMD_IBMCLOUD"
+
+	// EnvIBMCloudAccount names the account of the SL_USERNAME and SL_API_KEY credentials
+	EnvIBMCloudAccount = "VCMD_IBMCLOUD_ACCOUNT"
+
+	defaultIBMCloudAccount = "default"
+)
+
+// envProvider reads a single vCenter from the govc variables, a single IBM Cloud account from
+// the softlayer-go variables, and any number of both from the VCMD_* JSON variables
+type envProvider struct{}
+
+func (envProvider) Name() string { return ProviderEnv }
+
+func (envProvider) Credentials(ctx context.Context) (*Credentials, error) {
+	creds := &Credentials{
+		VCenters: make(map[string]vsphere.VCenterCredential),
+		IBMCloud: make(map[string]ibmcloud.SoftlayerCredentials),
+	}
+
+	if v := os.Getenv(EnvVCenters); v != "" {
+		if err := json.Unmarshal([]byte(v), &creds.VCenters); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", EnvVCenters, err)
+		}
+	}
+	if v := os.Getenv(EnvIBMCloud); v != "" {
+		if err := json.Unmarshal([]byte(v), &creds.IBMCloud); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", EnvIBMCloud, err)
+		}
+	}
+
+	server, credential, err := govcCredential()
+	if err != nil {
+		return nil, err
+	}
+	if server != "" {
+		if _, ok := creds.VCenters[server]; !ok {
+			creds.VCenters[server] = credential
+		}
+	}
+
+	username, apiKey := os.Getenv("SL_USERNAME"), os.Getenv("SL_API_KEY")
+	if username != "" && apiKey != "" {
+		account := os.Getenv(EnvIBMCloudAccount)
+		if account == "" {
+			account = defaultIBMCloudAccount
+		}
+		if _, ok := creds.IBMCloud[account]; !ok {
+			creds.IBMCloud[account] = ibmcloud.SoftlayerCredentials{Username: username, ApiToken: apiKey}
+		}
+	}
+
+	return creds, nil
+}
+
+// govcCredential returns the vCenter of GOVC_URL, which may be a bare host or a url with user
+// info, GOVC_USERNAME and GOVC_PASSWORD take precedence over the user info
+func govcCredential() (string, vsphere.VCenterCredential, error) {
+	var credential vsphere.VCenterCredential
+
+	govcURL := os.Getenv("GOVC_URL")
+	if govcURL == "" {
+		return "", credential, nil
+	}
+	if !strings.Contains(govcURL, "://") {
+		govcURL = "https://" + govcURL
+	}
+	u, err := url.Parse(govcURL)
+	if err != nil {
+		// the url error repeats the url, which may include the password
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", credential, fmt.Errorf("unable to parse GOVC_URL: %w", err)
+	}
+
+	if u.User != nil {
+		credential.Username = u.User.Username()
+		credential.Password, _ = u.User.Password()
+	}
+	if v := os.Getenv("GOVC_USERNAME"); v != "" {
+		credential.Username = v
+	}
+	if v := os.Getenv("GOVC_PASSWORD"); v != "" {
+		credential.Password = v
+	}
+	credential.CAFile = os.Getenv("GOVC_TLS_CA_CERTS")
+
+	if credential.Username == "" || credential.Password == "" {
+		return "", credential, nil
+	}
+	return u.Host, credential, nil
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const execTimeout = time.Minute
+
+// execProvider runs the credential helper once and parses the Credentials JSON it prints on
+// stdout. The command line is split on whitespace and run without a shell, stderr is passed through.
+type execProvider struct {
+	command string
+}
+
+func (*execProvider) Name() string { return ProviderExec }
+
+func (p *execProvider) Credentials(ctx context.Context) (*Credentials, error) {
+	args := strings.Fields(p.command)
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %w", args[0], err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		// the output is not included, it holds the secrets
+		return nil, fmt.Errorf("credential helper %s printed invalid JSON", args[0])
+	}
+	return &creds, nil
+}
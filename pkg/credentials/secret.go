@@ -0,0 +1,101 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/ibmcloud"
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/vsphere"
+)
+
+const (
+	// SecretKeyVCenters and SecretKeyIBMCloud hold the JSON of the vCenter and IBM Cloud auth files
+	SecretKeyVCenters = "vcenter.json"
+	SecretKeyIBMCloud = "ibmcloud.json"
+
+	// OpenShift's vsphere-creds Secret keys are <server>.username and <server>.password
+	usernameKeySuffix = ".username"
+	passwordKeySuffix = ".password"
+)
+
+// secretProvider reads a Kubernetes Secret using the kubeconfig, or the in-cluster config
+type secretProvider struct {
+	secret string
+}
+
+func (*secretProvider) Name() string { return ProviderSecret }
+
+func (p *secretProvider) Credentials(ctx context.Context) (*Credentials, error) {
+	if p.secret == "" {
+		return nil, nil
+	}
+
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+
+	namespace, name, ok := strings.Cut(p.secret, "/")
+	if !ok {
+		name = namespace
+		var err error
+		namespace, _, err = config.Namespace()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSecretData(fmt.Sprintf("%s/%s", namespace, name), secret.Data)
+}
+
+// parseSecretData reads the auth file keys and the vsphere-creds keys of the Secret data
+func parseSecretData(secret string, data map[string][]byte) (*Credentials, error) {
+	creds := &Credentials{
+		VCenters: make(map[string]vsphere.VCenterCredential),
+		IBMCloud: make(map[string]ibmcloud.SoftlayerCredentials),
+	}
+
+	if b, ok := data[SecretKeyVCenters]; ok {
+		if err := json.Unmarshal(b, &creds.VCenters); err != nil {
+			return nil, fmt.Errorf("unable to parse %s of Secret %s: %w", SecretKeyVCenters, secret, err)
+		}
+	}
+	if b, ok := data[SecretKeyIBMCloud]; ok {
+		if err := json.Unmarshal(b, &creds.IBMCloud); err != nil {
+			return nil, fmt.Errorf("unable to parse %s of Secret %s: %w", SecretKeyIBMCloud, secret, err)
+		}
+	}
+
+	for key, username := range data {
+		server, ok := strings.CutSuffix(key, usernameKeySuffix)
+		if !ok {
+			continue
+		}
+		password, ok := data[server+passwordKeySuffix]
+		if !ok {
+			continue
+		}
+		if _, ok := creds.VCenters[server]; !ok {
+			creds.VCenters[server] = vsphere.VCenterCredential{Username: string(username), Password: string(password)}
+		}
+	}
+
+	return creds, nil
+}
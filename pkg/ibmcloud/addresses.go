@@ -0,0 +1,138 @@
+package ibmcloud
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+)
+
+// Reservations contains addresses and ranges that must never be handed out to clusters.
+type Reservations []*net.IPNet
+
+// Contains determines if the ip address is covered by any reservation
+func (r Reservations) Contains(ip net.IP) bool {
+	for _, n := range r {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadReservations parses a reservations file. Each line contains a single
+// ip address or a CIDR, empty lines and lines starting with # are ignored.
+func ReadReservations(fileName string) (Reservations, error) {
+	var reservations Reservations
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.Contains(line, "/") {
+			ip := net.ParseIP(line)
+			if ip == nil {
+				return nil, fmt.Errorf("%s:%d: invalid ip address %q", fileName, lineNumber, line)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			reservations = append(reservations, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", fileName, lineNumber, err)
+		}
+		reservations = append(reservations, ipNet)
+	}
+
+	return reservations, scanner.Err()
+}
+
+// isReservedNote determines if the note IBM attached to an ip address marks it
+// as not available, i.e. it starts with the word "reserved", e.g. "Reserved for
+// HSRP.", or has the word "HSRP". A note such as "not reserved" keeps the address.
+func isReservedNote(note *string) bool {
+	if note == nil {
+		return false
+	}
+	words := strings.FieldsFunc(strings.ToLower(*note), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return false
+	}
+	return words[0] == "reserved" || slices.Contains(words, "hsrp")
+}
+
+func isSet(b *bool) bool {
+	return b != nil && *b
+}
+
+// UsableIPAddresses returns the ip addresses of the subnet that are safe to
+// hand to clusters, sorted in ascending order. The network, broadcast and
+// gateway addresses, addresses IBM marks as reserved (including HSRP) and
+// addresses covered by reservations are removed. A count of zero or less
+// returns the whole usable range.
+func UsableIPAddresses(subnet datatypes.Network_Subnet, count int, reservations Reservations) []net.IP {
+	excluded := make(map[string]bool)
+	for _, s := range []*string{subnet.NetworkIdentifier, subnet.Gateway, subnet.BroadcastAddress} {
+		if s != nil {
+			if ip := net.ParseIP(*s); ip != nil {
+				excluded[ip.String()] = true
+			}
+		}
+	}
+
+	usable := make([]net.IP, 0, len(subnet.IpAddresses))
+	for _, ipAddress := range subnet.IpAddresses {
+		if ipAddress.IpAddress == nil {
+			continue
+		}
+		if isSet(ipAddress.IsNetwork) || isSet(ipAddress.IsBroadcast) || isSet(ipAddress.IsGateway) || isSet(ipAddress.IsReserved) {
+			continue
+		}
+		if isReservedNote(ipAddress.Note) {
+			continue
+		}
+
+		ip := net.ParseIP(*ipAddress.IpAddress)
+		if ip == nil || excluded[ip.String()] || reservations.Contains(ip) {
+			continue
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		usable = append(usable, ip)
+	}
+
+	sort.Slice(usable, func(i, j int) bool {
+		return bytes.Compare(usable[i].To16(), usable[j].To16()) < 0
+	})
+
+	if count > 0 && count < len(usable) {
+		usable = usable[:count]
+	}
+
+	return usable
+}
@@ -0,0 +1,36 @@
+package ibmcloud
+
+import (
+	"testing"
+
+	"github.com/softlayer/softlayer-go/sl"
+)
+
+func TestIsReservedNote(t *testing.T) {
+	for _, tc := range []struct {
+		note     *string
+		expected bool
+	}{
+		{nil, false},
+		{sl.String(""), false},
+		{sl.String("Reserved for HSRP."), true},
+		{sl.String("reserved"), true},
+		{sl.String("RESERVED: bastion"), true},
+		{sl.String("HSRP"), true},
+		{sl.String("hsrp standby"), true},
+		{sl.String("not reserved"), false},
+		{sl.String("unreserved"), false},
+		{sl.String("reservedness"), false},
+		{sl.String("ci cluster"), false},
+	} {
+		name := "nil"
+		if tc.note != nil {
+			name = *tc.note
+		}
+		t.Run(name, func(t *testing.T) {
+			if got := isReservedNote(tc.note); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
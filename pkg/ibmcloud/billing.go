@@ -0,0 +1,29 @@
+package ibmcloud
+
+import (
+	"github.com/softlayer/softlayer-go/datatypes"
+)
+
+// MonthlyFee returns the recurring monthly fee of a billing item, the next invoice
+// total including child items is preferred over the recurring fee of the item itself.
+// Zero is returned if the item is nil or has no fee, e.g. it is billed to another account.
+func MonthlyFee(item *datatypes.Billing_Item) float64 {
+	if item == nil {
+		return 0
+	}
+	if item.NextInvoiceTotalRecurringAmount != nil {
+		return float64(*item.NextInvoiceTotalRecurringAmount)
+	}
+	if item.RecurringFee != nil {
+		return float64(*item.RecurringFee)
+	}
+	return 0
+}
+
+// HardwareMonthlyFee returns the recurring monthly fee of a bare metal server
+func HardwareMonthlyFee(hw datatypes.Hardware) float64 {
+	if hw.BillingItem == nil {
+		return 0
+	}
+	return MonthlyFee(&hw.BillingItem.Billing_Item)
+}
@@ -0,0 +1,85 @@
+package ibmcloud
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const defaultPageSize = 100
+
+// objectFilter builds a SoftLayer object filter, see
+// https://sldn.softlayer.com/article/object-filters/
+type objectFilter map[string]any
+
+// leaf returns the map at the dot separated property path, creating it if required
+func (f objectFilter) leaf(path string) map[string]any {
+	current := map[string]any(f)
+	for _, p := range strings.Split(path, ".") {
+		next, ok := current[p].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[p] = next
+		}
+		current = next
+	}
+	return current
+}
+
+// equals matches objects where the property at path equals value
+func (f objectFilter) equals(path, value string) objectFilter {
+	f.leaf(path)["operation"] = value
+	return f
+}
+
+// in matches objects where the property at path equals one of the values
+func (f objectFilter) in(path string, values []string) objectFilter {
+	leaf := f.leaf(path)
+	leaf["operation"] = "in"
+	leaf["options"] = []map[string]any{{
+		"name":  "data",
+		"value": values,
+	}}
+	return f
+}
+
+// orderByID sorts the objects of the property, e.g. subnets, by ascending id. Paged queries
+// must be sorted, the api does not otherwise keep the order of the objects between pages.
+func (f objectFilter) orderByID(property string) objectFilter {
+	leaf := f.leaf(property + ".id")
+	leaf["operation"] = "orderBy"
+	leaf["options"] = []map[string]any{{
+		"name":  "sort",
+		"value": []string{"ASC"},
+	}}
+	return f
+}
+
+func (f objectFilter) String() string {
+	if len(f) == 0 {
+		return ""
+	}
+	// marshalling nested maps of strings can not fail
+	b, _ := json.Marshal(f)
+	return string(b)
+}
+
+// allPages calls get with increasing offsets until a page smaller than the
+// page size is returned, and returns the results of all pages. The filter of
+// the requests must order the objects, see orderByID.
+func allPages[T any](pageSize int, get func(limit, offset int) ([]T, error)) ([]T, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var all []T
+	for offset := 0; ; offset += pageSize {
+		page, err := get(pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ibmcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/internal/ibmcloudtest"
+)
+
+const fakeAccount = "fake-account"
+
+// newFakeMetadata returns metadata of an account answered by a fake transport
+func newFakeMetadata(t *testing.T, responses map[string]string) (*Metadata, *ibmcloudtest.FakeTransport) {
+	t.Helper()
+	transport := ibmcloudtest.NewFakeTransport(responses)
+	m := NewMetadata()
+	m.Transport = transport
+	if err := m.AddCredentials(fakeAccount, "user", "token"); err != nil {
+		t.Fatal(err)
+	}
+	return m, transport
+}
+
+func TestObjectFilter(t *testing.T) {
+	filter := objectFilter{}.equals("subnets.version", "6").orderByID("subnets")
+
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(filter.String()), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"subnets": map[string]any{
+			"version": map[string]any{"operation": "6"},
+			"id": map[string]any{
+				"operation": "orderBy",
+				"options":   []any{map[string]any{"name": "sort", "value": []any{"ASC"}}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("expected filter %v, got %v", expected, parsed)
+	}
+
+	if s := (objectFilter{}).String(); s != "" {
+		t.Errorf("expected an empty filter, got %s", s)
+	}
+}
+
+func TestAllPagesOrdered(t *testing.T) {
+	var subnets []string
+	for id := 1; id <= 5; id++ {
+		subnets = append(subnets, fmt.Sprintf(`{"id": %d}`, id))
+	}
+	m, transport := newFakeMetadata(t, map[string]string{
+		"SoftLayer_Account::getSubnets": "[" + strings.Join(subnets, ",") + "]",
+	})
+	m.PageSize = 2
+
+	got, err := m.getSubnets(fakeAccount, objectFilter{}.equals("subnets.version", "4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, s := range got {
+		ids = append(ids, *s.Id)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("expected subnets 1 to 5, got %v", ids)
+	}
+
+	pages := 0
+	for _, r := range transport.Requests {
+		if r.Method != "getSubnets" {
+			continue
+		}
+		pages++
+		if !strings.Contains(r.Filter, `"id":{"operation":"orderBy"`) {
+			t.Errorf("page %d is not ordered by id: %s", pages, r.Filter)
+		}
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+}
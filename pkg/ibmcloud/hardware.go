@@ -0,0 +1,102 @@
+package ibmcloud
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+)
+
+const hardwareMask = `mask[id,hostname,fullyQualifiedDomainName,primaryIpAddress,primaryBackendIpAddress,networkManagementIpAddress,processorPhysicalCoreAmount,memoryCapacity,datacenter[name],rack[name,longName],serverRoom[longName],processors[hardwareComponentModel[longDescription]],networkComponents[name,port,speed,maxSpeed,primaryIpAddress],networkVlans[vlanNumber,podName,networkSpace],billingItem[id,recurringFee,nextInvoiceTotalRecurringAmount]]`
+
+// GetHardware returns the bare metal servers of the account in the datacenter, every
+// server of the account if the datacenter is empty.
+func (m *Metadata) GetHardware(account, datacenterName string) ([]datatypes.Hardware, error) {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := objectFilter{}
+	if datacenterName != "" {
+		filter.equals("hardware.datacenter.name", datacenterName)
+	}
+
+	key := filter.orderByID("hardware").String()
+	if hardware, ok := sess.HardwareCache[key]; ok {
+		return hardware, nil
+	}
+
+	var hardware []datatypes.Hardware
+	if !m.Cache.Get(cache.KindIBMHardware, account+"/"+key, &hardware) {
+		hardware, err = allPages(m.PageSize, func(limit, offset int) ([]datatypes.Hardware, error) {
+			return sess.AccountSession.Mask(hardwareMask).Filter(key).Limit(limit).Offset(offset).GetHardware()
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.putCache(cache.KindIBMHardware, account+"/"+key, hardware)
+	}
+
+	sess.HardwareCache[key] = hardware
+	return hardware, nil
+}
+
+// FindHardware returns the server with an address in ipAddresses or, if none has one,
+// the server with the hostname. The hostname matches the fully qualified domain name
+// or the short hostname of the server. nil is returned if there is no match.
+func FindHardware(hardware []datatypes.Hardware, ipAddresses []net.IP, hostname string) *datatypes.Hardware {
+	addresses := make(map[string]bool, len(ipAddresses))
+	for _, ip := range ipAddresses {
+		addresses[ip.String()] = true
+	}
+
+	for i, hw := range hardware {
+		candidates := []*string{hw.PrimaryIpAddress, hw.PrimaryBackendIpAddress, hw.NetworkManagementIpAddress}
+		for _, nc := range hw.NetworkComponents {
+			candidates = append(candidates, nc.PrimaryIpAddress)
+		}
+		for _, c := range candidates {
+			if c == nil {
+				continue
+			}
+			if ip := net.ParseIP(*c); ip != nil && addresses[ip.String()] {
+				return &hardware[i]
+			}
+		}
+	}
+
+	if hostname == "" {
+		return nil
+	}
+
+	shortName := strings.SplitN(hostname, ".", 2)[0]
+	for i, hw := range hardware {
+		if hw.FullyQualifiedDomainName != nil && strings.EqualFold(*hw.FullyQualifiedDomainName, hostname) {
+			return &hardware[i]
+		}
+		if hw.Hostname != nil && strings.EqualFold(*hw.Hostname, shortName) {
+			return &hardware[i]
+		}
+	}
+
+	return nil
+}
+
+// HardwarePod returns the pod of the server's private vlan, empty if unknown
+func HardwarePod(hw datatypes.Hardware) string {
+	for _, v := range hw.NetworkVlans {
+		if v.PodName != nil && v.NetworkSpace != nil && *v.NetworkSpace == "PRIVATE" {
+			return *v.PodName
+		}
+	}
+	for _, v := range hw.NetworkVlans {
+		if v.PodName != nil {
+			return *v.PodName
+		}
+	}
+	return ""
+}
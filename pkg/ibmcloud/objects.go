@@ -3,10 +3,12 @@ package ibmcloud
 import (
 	"context"
 	"fmt"
+	"log"
 	"net"
-	"strings"
 
 	"github.com/softlayer/softlayer-go/datatypes"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
 )
 
 /*
@@ -100,8 +102,8 @@ import (
 */
 
 const (
-	vlanSubnetMask    = `mask[id,name,vlanNumber,podName,fullyQualifiedName,datacenter[name],subnets[ipAddressCount,gateway,cidr,netmask,networkIdentifier,subnetType,ipAddresses[ipAddress]],primaryRouter[hostname],tagReferences[tag[name]]]`
-	networkSubnetMask = `mask[id,cidr,gateway,tagReferences[tag[name]],version]`
+	vlanSubnetMask    = `mask[id,name,vlanNumber,podName,fullyQualifiedName,datacenter[name],subnets[id,version,ipAddressCount,gateway,broadcastAddress,cidr,netmask,networkIdentifier,subnetType,ipAddresses[ipAddress,isNetwork,isBroadcast,isGateway,isReserved,note],billingItem[id,recurringFee,nextInvoiceTotalRecurringAmount],tagReferences[tag[name]]],primaryRouter[hostname],tagReferences[tag[name]],billingItem[id,recurringFee,nextInvoiceTotalRecurringAmount]]`
+	networkSubnetMask = `mask[id,version,podName,ipAddressCount,gateway,broadcastAddress,cidr,netmask,networkIdentifier,subnetType,ipAddresses[ipAddress,isNetwork,isBroadcast,isGateway,isReserved,note],billingItem[id,recurringFee,nextInvoiceTotalRecurringAmount],tagReferences[tag[name]]]`
 
 	//backup copy before removal of parameters that maybe we don't need to make the config more readable
 	//vlanSubnetMask = `mask[id,name,vlanNumber,podName,fullyQualifiedName,datacenter[name],subnets[id,ipAddressCount,gateway,cidr,netmask,networkIdentifier,subnetType,ipAddresses[ipAddress,isNetwork,isBroadcast,isGateway]],primaryRouter[hostname]]`
@@ -114,92 +116,173 @@ type VCenterLocation struct {
 	VlanNumber            *int
 
 	IPAddress net.IP
+
+	// Strategy is the method used to locate the vCenter
+	Strategy string
 }
 
-func (m *Metadata) GetSubnetsByTag(account, datacenterName, podName, tag string) (*[]datatypes.Network_Subnet, error) {
-	_, err := m.Session(context.TODO(), account)
+func (m *Metadata) putCache(kind cache.Kind, key string, v any) {
+	if err := m.Cache.Put(kind, key, v); err != nil {
+		log.Printf("WARNING: unable to cache %s %s: %v", kind, key, err)
+	}
+}
+
+// getSubnets retrieves the subnets matching the filter one page at a time,
+// results are cached for the lifetime of the session and in the optional cache.
+func (m *Metadata) getSubnets(account string, filter objectFilter) ([]datatypes.Network_Subnet, error) {
+	sess, err := m.Session(context.TODO(), account)
 	if err != nil {
 		return nil, err
 	}
 
-	if m.sessions[account].SubnetsCache == nil || len(*m.sessions[account].SubnetsCache) == 0 {
-		subnets, err := m.sessions[account].AccountSession.Mask(networkSubnetMask).GetSubnets()
+	key := filter.orderByID("subnets").String()
+	if subnets, ok := sess.SubnetsCache[key]; ok {
+		return subnets, nil
+	}
+
+	var subnets []datatypes.Network_Subnet
+	if !m.Cache.Get(cache.KindIBMSubnets, account+"/"+key, &subnets) {
+		subnets, err = allPages(m.PageSize, func(limit, offset int) ([]datatypes.Network_Subnet, error) {
+			return sess.AccountSession.Mask(networkSubnetMask).Filter(key).Limit(limit).Offset(offset).GetSubnets()
+		})
 		if err != nil {
 			return nil, err
 		}
-
-		m.sessions[account].SubnetsCache = &subnets
+		m.putCache(cache.KindIBMSubnets, account+"/"+key, subnets)
 	}
 
-	taggedSubnets := make([]datatypes.Network_Subnet, 0, len(*m.sessions[account].SubnetsCache))
+	sess.SubnetsCache[key] = subnets
+	return subnets, nil
+}
 
-	// I am pretty sure there is a way to do this by the api but /shrug this seems easier at the moment
+// getNetworkVlans retrieves the vlans matching the filter one page at a time,
+// results are cached for the lifetime of the session and in the optional cache.
+func (m *Metadata) getNetworkVlans(account string, filter objectFilter) ([]datatypes.Network_Vlan, error) {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, s := range *m.sessions[account].SubnetsCache {
-		for _, tagRef := range s.TagReferences {
-			if strings.Contains(*tagRef.Tag.Name, tag) {
-				taggedSubnets = append(taggedSubnets, s)
-			}
+	key := filter.orderByID("networkVlans").String()
+	if vlans, ok := sess.NetworkVlansCache[key]; ok {
+		return vlans, nil
+	}
+
+	var vlans []datatypes.Network_Vlan
+	if !m.Cache.Get(cache.KindIBMVlans, account+"/"+key, &vlans) {
+		vlans, err = allPages(m.PageSize, func(limit, offset int) ([]datatypes.Network_Vlan, error) {
+			return sess.AccountSession.Mask(vlanSubnetMask).Filter(key).Limit(limit).Offset(offset).GetNetworkVlans()
+		})
+		if err != nil {
+			return nil, err
 		}
+		m.putCache(cache.KindIBMVlans, account+"/"+key, vlans)
 	}
 
-	return &taggedSubnets, nil
+	sess.NetworkVlansCache[key] = vlans
+	return vlans, nil
 }
 
-func (m *Metadata) GetVlanSubnets(account, datacenterName, podName string) (*[]datatypes.Network_Vlan, error) {
-	_, err := m.Session(context.TODO(), account)
+// TaggedSubnet a subnet and its parsed vcm tags
+type TaggedSubnet struct {
+	datatypes.Network_Subnet
+	Tags Tags
+}
+
+// GetVlanTaggedSubnets returns the subnets tagged vcm:vlan=<vlan> or pri_<vlan> in the datacenter
+// and pod when set, vlan numbers are only unique per pod. Subnets with invalid tags are skipped
+// with a warning.
+func (m *Metadata) GetVlanTaggedSubnets(account, datacenterName, podName string, vlanNumber int) ([]TaggedSubnet, error) {
+	filter := objectFilter{}.in("subnets.tagReferences.tag.name", []string{VlanTag(vlanNumber), LegacyVlanTag(vlanNumber)})
+	if datacenterName != "" {
+		filter.equals("subnets.datacenter.name", datacenterName)
+	}
+	if podName != "" {
+		filter.equals("subnets.podName", podName)
+	}
+
+	subnets, err := m.getSubnets(account, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	if m.sessions[account].NetworkVlansCache == nil || len(*m.sessions[account].NetworkVlansCache) == 0 {
-		vlans, err := m.sessions[account].AccountSession.Mask(vlanSubnetMask).GetNetworkVlans()
+	taggedSubnets := make([]TaggedSubnet, 0, len(subnets))
+	for _, s := range subnets {
+		// in case the api ignored the filter
+		if podName != "" && (s.PodName == nil || *s.PodName != podName) {
+			continue
+		}
+		tags, err := ParseTags(TagNames(s.TagReferences))
 		if err != nil {
-			return nil, err
+			log.Printf("WARNING: ignoring subnet %d with invalid tags: %v", *s.Id, err)
+			continue
+		}
+		if tags.Vlan != vlanNumber {
+			continue
 		}
+		taggedSubnets = append(taggedSubnets, TaggedSubnet{Network_Subnet: s, Tags: tags})
+	}
+
+	return taggedSubnets, nil
+}
 
-		m.sessions[account].NetworkVlansCache = &vlans
+func (m *Metadata) GetVlanSubnets(account, datacenterName, podName string) (*[]datatypes.Network_Vlan, error) {
+	filter := objectFilter{}
+	if datacenterName != "" && podName != "" {
+		filter.equals("networkVlans.primaryRouter.datacenter.name", datacenterName)
+		filter.equals("networkVlans.podName", podName)
 	}
 
-	subsetNetworkVlans := make([]datatypes.Network_Vlan, 0, len(*m.sessions[account].NetworkVlansCache))
+	vlans, err := m.getNetworkVlans(account, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	if datacenterName != "" && podName != "" {
-		for _, v := range *m.sessions[account].NetworkVlansCache {
+		subsetNetworkVlans := make([]datatypes.Network_Vlan, 0, len(vlans))
+		for _, v := range vlans {
 			if *v.Datacenter.Name == datacenterName && *v.PodName == podName {
-				// ** NOTE ** removing all but the first 20
-				maxIpAddresses := uint(20)
-				for i, subnet := range v.Subnets {
-					if *subnet.IpAddressCount < maxIpAddresses {
-						maxIpAddresses = *subnet.IpAddressCount
-					}
-
-					v.Subnets[i].IpAddresses = v.Subnets[i].IpAddresses[:maxIpAddresses]
-				}
-
 				subsetNetworkVlans = append(subsetNetworkVlans, v)
 			}
 		}
 		return &subsetNetworkVlans, nil
 	}
 
-	return m.sessions[account].NetworkVlansCache, nil
+	return &vlans, nil
 }
 
 func (m *Metadata) FindVCenterPhyDC(account string, vCenterIPAddresses []net.IP) (*VCenterLocation, error) {
-	var vcloc VCenterLocation
-
-	_, err := m.Session(context.TODO(), account)
-	if err != nil {
-		return nil, err
+	addresses := make([]string, 0, len(vCenterIPAddresses))
+	for _, ip := range vCenterIPAddresses {
+		addresses = append(addresses, ip.String())
 	}
 
-	vlans, err := m.GetVlanSubnets(account, "", "")
-	if err != nil {
-		return nil, err
+	// first only retrieve the vlans with a subnet containing the vCenter addresses,
+	// if none of them match fall back to searching every vlan of the account.
+	for _, filter := range []objectFilter{
+		objectFilter{}.in("networkVlans.subnets.ipAddresses.ipAddress", addresses),
+		{},
+	} {
+		vlans, err := m.getNetworkVlans(account, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		vcloc, err := findVlanLocation(vlans, vCenterIPAddresses)
+		if err != nil {
+			return nil, err
+		}
+		if vcloc.DatacenterName != nil {
+			return vcloc, nil
+		}
 	}
 
-vlanloop:
-	for _, v := range *vlans {
+	return &VCenterLocation{}, nil
+}
+
+// findVlanLocation returns the location of the first vlan with a subnet containing one of the ip addresses
+func findVlanLocation(vlans []datatypes.Network_Vlan, ipAddresses []net.IP) (*VCenterLocation, error) {
+	for _, v := range vlans {
 		for _, s := range v.Subnets {
 			ip := fmt.Sprintf("%s/%d", *s.NetworkIdentifier, *s.Cidr)
 
@@ -208,18 +291,19 @@ vlanloop:
 				return nil, err
 			}
 
-			for _, vcIP := range vCenterIPAddresses {
+			for _, vcIP := range ipAddresses {
 				if ipNet.Contains(vcIP) {
-					vcloc.PrimaryRouterHostname = v.PrimaryRouter.Hostname
-					vcloc.PodName = v.PodName
-					vcloc.DatacenterName = v.Datacenter.Name
-					vcloc.IPAddress = vcIP
-					vcloc.VlanNumber = v.VlanNumber
-					break vlanloop
+					return &VCenterLocation{
+						PrimaryRouterHostname: v.PrimaryRouter.Hostname,
+						PodName:               v.PodName,
+						DatacenterName:        v.Datacenter.Name,
+						IPAddress:             vcIP,
+						VlanNumber:            v.VlanNumber,
+					}, nil
 				}
 			}
 		}
 	}
 
-	return &vcloc, nil
+	return &VCenterLocation{}, nil
 }
@@ -0,0 +1,90 @@
+package ibmcloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/services"
+	"github.com/softlayer/softlayer-go/sl"
+)
+
+const (
+	// portableSubnetPackageId is the additional products package portable subnets are ordered from
+	portableSubnetPackageId = 0
+
+	portableSubnetItemMask = `mask[id,keyName,capacity,description,itemCategory[categoryCode],prices[id,locationGroupId,recurringFee]]`
+
+	AddressSpacePrivate = "private"
+	AddressSpacePublic  = "public"
+)
+
+// portableSubnetCategories are the item categories of portable IPv4 subnets by address space
+var portableSubnetCategories = map[string]string{
+	AddressSpacePrivate: "sov_sec_ip_addresses_priv",
+	AddressSpacePublic:  "sov_sec_ip_addresses_pub",
+}
+
+// NewPortableSubnetOrder builds the order of a portable IPv4 subnet of size addresses
+// on the vlan, using the standard price of the matching item.
+func (m *Metadata) NewPortableSubnetOrder(account string, vlanId int, addressSpace string, size int) (*datatypes.Container_Product_Order_Network_Subnet, error) {
+	category, ok := portableSubnetCategories[addressSpace]
+	if !ok {
+		return nil, fmt.Errorf("unknown address space %s, must be %s or %s", addressSpace, AddressSpacePrivate, AddressSpacePublic)
+	}
+
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := services.GetProductPackageService(sess.Session).Id(portableSubnetPackageId).Mask(portableSubnetItemMask).GetItems()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.ItemCategory == nil || item.ItemCategory.CategoryCode == nil || *item.ItemCategory.CategoryCode != category {
+			continue
+		}
+		if item.Capacity == nil || int(*item.Capacity) != size {
+			continue
+		}
+
+		for _, price := range item.Prices {
+			// prices with a location group are regional, the standard price has none
+			if price.Id == nil || price.LocationGroupId != nil {
+				continue
+			}
+			return &datatypes.Container_Product_Order_Network_Subnet{
+				Container_Product_Order: datatypes.Container_Product_Order{
+					ComplexType: sl.String("SoftLayer_Container_Product_Order_Network_Subnet"),
+					PackageId:   sl.Int(portableSubnetPackageId),
+					Prices:      []datatypes.Product_Item_Price{{Id: price.Id}},
+					Quantity:    sl.Int(1),
+				},
+				EndPointVlanId: sl.Int(vlanId),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no %s portable subnet of %d addresses is offered", addressSpace, size)
+}
+
+// VerifyOrder checks the order without placing it and returns the priced order
+func (m *Metadata) VerifyOrder(account string, order any) (datatypes.Container_Product_Order, error) {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return datatypes.Container_Product_Order{}, err
+	}
+	return services.GetProductOrderService(sess.Session).VerifyOrder(order)
+}
+
+// PlaceOrder places the order, it is billed to the account
+func (m *Metadata) PlaceOrder(account string, order any) (datatypes.Container_Product_Order_Receipt, error) {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return datatypes.Container_Product_Order_Receipt{}, err
+	}
+	return services.GetProductOrderService(sess.Session).PlaceOrder(order, sl.Bool(false))
+}
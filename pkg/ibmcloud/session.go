@@ -7,6 +7,8 @@ import (
 	"github.com/softlayer/softlayer-go/datatypes"
 	"github.com/softlayer/softlayer-go/services"
 	"github.com/softlayer/softlayer-go/session"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
 )
 
 type SoftlayerCredentials struct {
@@ -18,19 +20,31 @@ type SoftlayerSession struct {
 	Session        *session.Session
 	AccountSession services.Account
 
-	NetworkVlansCache *[]datatypes.Network_Vlan
-	SubnetsCache      *[]datatypes.Network_Subnet
+	// NetworkVlansCache, SubnetsCache and HardwareCache are keyed by object filter
+	NetworkVlansCache map[string][]datatypes.Network_Vlan
+	SubnetsCache      map[string][]datatypes.Network_Subnet
+	HardwareCache     map[string][]datatypes.Hardware
 }
 
 type Metadata struct {
 	sessions    map[string]*SoftlayerSession
 	credentials map[string]*SoftlayerCredentials
+
+	// PageSize is the number of objects retrieved per api request
+	PageSize int
+
+	// Cache optionally persists vlan and subnet lookups between runs
+	Cache *cache.Cache
+
+	// Transport replaces the SoftLayer rest transport of new sessions when set, tests set a fake
+	Transport session.TransportHandler
 }
 
 func NewMetadata() *Metadata {
 	return &Metadata{
 		sessions:    make(map[string]*SoftlayerSession),
 		credentials: make(map[string]*SoftlayerCredentials),
+		PageSize:    defaultPageSize,
 	}
 }
 
@@ -54,6 +68,14 @@ func (m *Metadata) Session(ctx context.Context, account string) (*SoftlayerSessi
 	return m.unlockedSession(ctx, account)
 }
 
+func (m *Metadata) newSession(account string) *session.Session {
+	sess := session.New(m.credentials[account].Username, m.credentials[account].ApiToken)
+	if sess != nil && m.Transport != nil {
+		sess.TransportHandler = m.Transport
+	}
+	return sess
+}
+
 func (m *Metadata) unlockedSession(ctx context.Context, account string) (*SoftlayerSession, error) {
 	var err error
 
@@ -63,7 +85,7 @@ func (m *Metadata) unlockedSession(ctx context.Context, account string) (*Softla
 
 		if m.sessions[account].Session != nil {
 			if _, err := m.sessions[account].AccountSession.GetCurrentUser(); err != nil {
-				m.sessions[account].Session = session.New(m.credentials[account].Username, m.credentials[account].ApiToken)
+				m.sessions[account].Session = m.newSession(account)
 				m.sessions[account].AccountSession = services.GetAccountService(m.sessions[account].Session)
 				if m.sessions[account].Session == nil {
 					return nil, fmt.Errorf("error getting session for account %s", account)
@@ -74,14 +96,17 @@ func (m *Metadata) unlockedSession(ctx context.Context, account string) (*Softla
 	}
 
 	// If we have gotten here there is no session for the server name, create.
-	tempSession := session.New(m.credentials[account].Username, m.credentials[account].ApiToken)
+	tempSession := m.newSession(account)
 	tempAccountSession := services.GetAccountService(tempSession)
 	if tempSession == nil {
 		return nil, fmt.Errorf("error getting session for account %s", account)
 	}
 	m.sessions[account] = &SoftlayerSession{
-		Session:        tempSession,
-		AccountSession: tempAccountSession,
+		Session:           tempSession,
+		AccountSession:    tempAccountSession,
+		NetworkVlansCache: make(map[string][]datatypes.Network_Vlan),
+		SubnetsCache:      make(map[string][]datatypes.Network_Subnet),
+		HardwareCache:     make(map[string][]datatypes.Hardware),
 	}
 
 	return m.sessions[account], err
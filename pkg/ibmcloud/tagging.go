@@ -0,0 +1,218 @@
+package ibmcloud
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/services"
+	"github.com/softlayer/softlayer-go/sl"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+const (
+	ipv6SubnetMask = `mask[id,version,podName,cidr,networkIdentifier,note,subnetType,datacenter[name],networkVlan[id,vlanNumber,name],endPointIpAddress[ipAddress,subnet[networkVlan[id,vlanNumber,name]]],tagReferences[tag[name]]]`
+
+	// subnetTagKeyName is the SoftLayer tag type of subnets
+	subnetTagKeyName = "NETWORK_SUBNET"
+
+	SubnetVlanSourceRouted = "routed vlan"
+	SubnetVlanSourceNote   = "note"
+)
+
+// noteVlan matches the vlan in a subnet note following the port group naming, e.g. ci-vlan-1234
+var noteVlan = regexp.MustCompile(`(?i)vlan[-_ ]?([0-9]+)`)
+
+// GetIPv6Subnets returns the IPv6 subnets of the account in the datacenter, every IPv6
+// subnet of the account if the datacenter is empty. The result is never cached.
+func (m *Metadata) GetIPv6Subnets(account, datacenterName string) ([]datatypes.Network_Subnet, error) {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := objectFilter{}.equals("subnets.version", "6")
+	if datacenterName != "" {
+		filter.equals("subnets.datacenter.name", datacenterName)
+	}
+	filter.orderByID("subnets")
+
+	return allPages(m.PageSize, func(limit, offset int) ([]datatypes.Network_Subnet, error) {
+		return sess.AccountSession.Mask(ipv6SubnetMask).Filter(filter.String()).Limit(limit).Offset(offset).GetSubnets()
+	})
+}
+
+// routedVlan returns the vlan the subnet is routed to, directly or through its end point address
+func routedVlan(subnet datatypes.Network_Subnet) *datatypes.Network_Vlan {
+	if v := subnet.NetworkVlan; v != nil && v.Id != nil {
+		return v
+	}
+	if ep := subnet.EndPointIpAddress; ep != nil && ep.Subnet != nil && ep.Subnet.NetworkVlan != nil && ep.Subnet.NetworkVlan.Id != nil {
+		return ep.Subnet.NetworkVlan
+	}
+	return nil
+}
+
+// noteVlanNumber returns the vlan number in the note of the subnet, zero if there is none
+func noteVlanNumber(subnet datatypes.Network_Subnet) int {
+	if subnet.Note == nil {
+		return 0
+	}
+	m := noteVlan.FindStringSubmatch(*subnet.Note)
+	if m == nil {
+		return 0
+	}
+	vlan, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return vlan
+}
+
+// subnetVlan returns the CI vlan a subnet belongs to and how it was found. The vlan the subnet
+// is routed to is matched by its object id, vlan numbers are only unique per pod. Otherwise a
+// vlan number in the note of the subnet is matched in the pod of the subnet, or in its datacenter
+// if that has a single CI vlan of the number. nil is returned if no CI vlan matches.
+func subnetVlan(subnet datatypes.Network_Subnet, ciVlans []datatypes.Network_Vlan) (*datatypes.Network_Vlan, string) {
+	if routed := routedVlan(subnet); routed != nil {
+		for i, v := range ciVlans {
+			if *v.Id == *routed.Id {
+				return &ciVlans[i], SubnetVlanSourceRouted
+			}
+		}
+		return nil, SubnetVlanSourceRouted
+	}
+
+	number := noteVlanNumber(subnet)
+	if number == 0 {
+		return nil, ""
+	}
+	var matched []*datatypes.Network_Vlan
+	for i, v := range ciVlans {
+		if *v.VlanNumber != number || *v.Datacenter.Name != *subnet.Datacenter.Name {
+			continue
+		}
+		if subnet.PodName != nil && *subnet.PodName != *v.PodName {
+			continue
+		}
+		matched = append(matched, &ciVlans[i])
+	}
+	if len(matched) != 1 {
+		return nil, SubnetVlanSourceNote
+	}
+	return matched[0], SubnetVlanSourceNote
+}
+
+// PlanSubnetTags adds the tag changes of the IPv6 subnets of the account's CI vlans to the plan.
+// The CI vlans are the vlans in the datacenter, every datacenter if empty, with the substring in
+// their name. Each CI vlan keeps a single IPv6 subnet, further subnets are skipped with a warning.
+func (m *Metadata) PlanSubnetTags(p *plan.Plan, account, datacenterName, vlanNameSubstring string) error {
+	vlans, err := m.GetVlanSubnets(account, "", "")
+	if err != nil {
+		return err
+	}
+
+	var ciVlans []datatypes.Network_Vlan
+	for _, v := range *vlans {
+		if v.Id == nil || v.Datacenter == nil || v.Datacenter.Name == nil || v.PodName == nil || v.VlanNumber == nil {
+			continue
+		}
+		if datacenterName != "" && *v.Datacenter.Name != datacenterName {
+			continue
+		}
+		if vlanNameSubstring != "" && (v.Name == nil || !strings.Contains(*v.Name, vlanNameSubstring)) {
+			continue
+		}
+		ciVlans = append(ciVlans, v)
+	}
+
+	subnets, err := m.GetIPv6Subnets(account, datacenterName)
+	if err != nil {
+		return err
+	}
+
+	tagged := make(map[int]string)
+	for _, s := range subnets {
+		if s.Id == nil || s.NetworkIdentifier == nil || s.Cidr == nil || s.Datacenter == nil || s.Datacenter.Name == nil {
+			continue
+		}
+		cidr := fmt.Sprintf("%s/%d", *s.NetworkIdentifier, *s.Cidr)
+
+		vlan, source := subnetVlan(s, ciVlans)
+		if vlan == nil {
+			if source == "" {
+				log.Printf("WARNING: unable to determine the vlan of subnet %s", cidr)
+			} else if source == SubnetVlanSourceNote {
+				log.Printf("WARNING: the %s of subnet %s does not match a single CI vlan", source, cidr)
+			}
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%d", *vlan.PodName, *vlan.VlanNumber)
+		if other, ok := tagged[*vlan.Id]; ok {
+			log.Printf("WARNING: vlan %s has more than one IPv6 subnet, keeping %s and skipping %s", key, other, cidr)
+			continue
+		}
+		tagged[*vlan.Id] = cidr
+
+		tags, changed := SubnetVlanTags(s, *vlan.VlanNumber)
+		if !changed {
+			continue
+		}
+
+		subnetId := *s.Id
+		p.Add(fmt.Sprintf("account %s subnet %s: vlan %s from %s, set tags %s", account, cidr, key, source, strings.Join(tags, ",")), func() error {
+			return m.SetSubnetTags(account, subnetId, tags)
+		})
+	}
+
+	return nil
+}
+
+// SubnetVlanTags returns the tags of the subnet with the vlan tag of vlanNumber and
+// without vlan tags of other vlans, and reports if they differ from the current tags.
+func SubnetVlanTags(subnet datatypes.Network_Subnet, vlanNumber int) ([]string, bool) {
+	current := TagNames(subnet.TagReferences)
+	tags := make([]string, 0, len(current)+1)
+
+	for _, name := range current {
+		if m := legacyVlanTag.FindStringSubmatch(name); m != nil && m[1] != strconv.Itoa(vlanNumber) {
+			continue
+		}
+		if key, value, _ := strings.Cut(name, "="); strings.EqualFold(key, TagVlan) && value != strconv.Itoa(vlanNumber) {
+			continue
+		}
+		tags = append(tags, name)
+	}
+	if !slices.Contains(tags, VlanTag(vlanNumber)) {
+		tags = append(tags, VlanTag(vlanNumber))
+	}
+
+	slices.Sort(current)
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return tags, !slices.Equal(current, sorted)
+}
+
+// SetSubnetTags replaces the tags of the subnet
+func (m *Metadata) SetSubnetTags(account string, subnetId int, tags []string) error {
+	sess, err := m.Session(context.TODO(), account)
+	if err != nil {
+		return err
+	}
+
+	ok, err := services.GetTagService(sess.Session).SetTags(sl.String(strings.Join(tags, ",")), sl.String(subnetTagKeyName), &subnetId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("setting the tags of subnet %d was refused", subnetId)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package ibmcloud
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+	"github.com/softlayer/softlayer-go/sl"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+var subnetTagResponses = map[string]string{
+	// vlan 1234 exists in both pods of dal10
+	"SoftLayer_Account::getNetworkVlans": `[
+		{"id": 100, "name": "ci-segment", "vlanNumber": 1234, "podName": "dal10.pod01", "datacenter": {"name": "dal10"}},
+		{"id": 101, "name": "mgmt", "vlanNumber": 1235, "podName": "dal10.pod01", "datacenter": {"name": "dal10"}},
+		{"id": 200, "name": "ci-segment", "vlanNumber": 1234, "podName": "dal10.pod02", "datacenter": {"name": "dal10"}},
+		{"id": 300, "name": "ci-segment", "vlanNumber": 1236, "podName": "wdc04.pod01", "datacenter": {"name": "wdc04"}}
+	]`,
+	"SoftLayer_Account::getSubnets": `[
+		{"id": 1, "version": 6, "podName": "dal10.pod02", "networkIdentifier": "2001:db8:2::", "cidr": 64, "datacenter": {"name": "dal10"},
+		 "networkVlan": {"id": 200, "vlanNumber": 1234}},
+		{"id": 2, "version": 6, "podName": "dal10.pod01", "networkIdentifier": "2001:db8:1::", "cidr": 64, "datacenter": {"name": "dal10"},
+		 "endPointIpAddress": {"subnet": {"networkVlan": {"id": 100, "vlanNumber": 1234}}},
+		 "tagReferences": [{"tag": {"name": "pri_1234"}}, {"tag": {"name": "owner=ci"}}]},
+		{"id": 3, "version": 6, "podName": "dal10.pod01", "networkIdentifier": "2001:db8:3::", "cidr": 64, "datacenter": {"name": "dal10"},
+		 "note": "ci-vlan-1234"},
+		{"id": 4, "version": 6, "networkIdentifier": "2001:db8:4::", "cidr": 64, "datacenter": {"name": "dal10"},
+		 "note": "ci-vlan-1234"},
+		{"id": 5, "version": 6, "podName": "dal10.pod01", "networkIdentifier": "2001:db8:5::", "cidr": 64, "datacenter": {"name": "dal10"},
+		 "networkVlan": {"id": 101, "vlanNumber": 1235}},
+		{"id": 6, "version": 6, "podName": "wdc04.pod01", "networkIdentifier": "2001:db8:6::", "cidr": 64, "datacenter": {"name": "wdc04"},
+		 "note": "ci-vlan-1236", "tagReferences": [{"tag": {"name": "vcm:vlan=1236"}}]},
+		{"id": 7, "version": 6, "networkIdentifier": "2001:db8:7::", "cidr": 64, "datacenter": {"name": "dal10"}}
+	]`,
+	"SoftLayer_Tag::setTags": `true`,
+}
+
+func TestPlanSubnetTags(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		datacenter string
+		expected   []string
+	}{
+		{
+			name:       "pods of a datacenter",
+			datacenter: "",
+			expected: []string{
+				// routed to the vlan 1234 of pod02, not the vlan 1234 of pod01
+				"account fake-account subnet 2001:db8:2::/64: vlan dal10.pod02/1234 from routed vlan, set tags vcm:vlan=1234",
+				"account fake-account subnet 2001:db8:1::/64: vlan dal10.pod01/1234 from routed vlan, set tags pri_1234,owner=ci,vcm:vlan=1234",
+				// subnet 3 is a second subnet of the vlan 1234 of pod01, subnet 4 without a pod matches
+				// both vlans 1234 of dal10, subnet 5 is not on a CI vlan and subnet 6 is tagged already
+			},
+		},
+		{
+			name:       "datacenter",
+			datacenter: "wdc04",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, transport := newFakeMetadata(t, subnetTagResponses)
+
+			p := &plan.Plan{}
+			if err := m.PlanSubnetTags(p, fakeAccount, tc.datacenter, "ci-"); err != nil {
+				t.Fatal(err)
+			}
+
+			var descriptions []string
+			for _, a := range p.Actions {
+				descriptions = append(descriptions, a.Description)
+			}
+			if !slices.Equal(descriptions, tc.expected) {
+				t.Fatalf("expected plan %q, got %q", tc.expected, descriptions)
+			}
+
+			if err := p.Apply(); err != nil {
+				t.Fatal(err)
+			}
+			var tagged []int
+			for _, r := range transport.Requests {
+				if r.Service != "SoftLayer_Tag" || r.Method != "setTags" {
+					continue
+				}
+				if len(r.Args) != 3 {
+					t.Fatalf("unexpected setTags arguments %v", r.Args)
+				}
+				tagged = append(tagged, *r.Args[2].(*int))
+			}
+			if len(tagged) != len(tc.expected) {
+				t.Errorf("expected %d subnets tagged, got %v", len(tc.expected), tagged)
+			}
+		})
+	}
+}
+
+func TestSubnetVlanTags(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		current  []string
+		vlan     int
+		expected []string
+		changed  bool
+	}{
+		{"untagged", nil, 1234, []string{"vcm:vlan=1234"}, true},
+		{"tagged", []string{"owner=ci", "vcm:vlan=1234"}, 1234, []string{"owner=ci", "vcm:vlan=1234"}, false},
+		{"legacy tag", []string{"pri_1234"}, 1234, []string{"pri_1234", "vcm:vlan=1234"}, true},
+		{"other vlan", []string{"pri_1235", "vcm:vlan=1235", "owner=ci"}, 1234, []string{"owner=ci", "vcm:vlan=1234"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			subnet := subnetWithTags(tc.current...)
+			tags, changed := SubnetVlanTags(subnet, tc.vlan)
+			if !slices.Equal(tags, tc.expected) || changed != tc.changed {
+				t.Errorf("expected %v changed %v, got %v changed %v", tc.expected, tc.changed, tags, changed)
+			}
+		})
+	}
+}
+
+func subnetWithTags(names ...string) datatypes.Network_Subnet {
+	var subnet datatypes.Network_Subnet
+	for _, name := range names {
+		subnet.TagReferences = append(subnet.TagReferences, datatypes.Tag_Reference{Tag: &datatypes.Tag{Name: sl.String(name)}})
+	}
+	return subnet
+}
@@ -0,0 +1,172 @@
+package ibmcloud
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/softlayer/softlayer-go/datatypes"
+)
+
+// The vcm tag schema of IBM Cloud vlans and subnets. Tags outside the schema are ignored.
+//
+//	vcm:vlan=<number>          the subnet belongs to the vlan, e.g. vcm:vlan=1234
+//	vcm:role=ipv4|ipv6         the subnet is an additional IPv4 or the IPv6 subnet of the vlan,
+//	                           defaults to the version of the subnet
+//	vcm:network-type=<type>    single-tenant, multi-tenant or disconnected, labels the Networks
+//	vcm:exclude                the vlan or subnet never produces a Network
+//
+// The legacy pri_<vlan> tag is equivalent to vcm:vlan=<vlan> on an IPv6 subnet.
+const (
+	TagPrefix      = "vcm:"
+	TagVlan        = "vcm:vlan"
+	TagRole        = "vcm:role"
+	TagNetworkType = "vcm:network-type"
+	TagExclude     = "vcm:exclude"
+
+	SubnetRoleIPv4 = "ipv4"
+	SubnetRoleIPv6 = "ipv6"
+)
+
+var legacyVlanTag = regexp.MustCompile(`^pri_([0-9]+)$`)
+
+// NetworkTypes are the valid values of the vcm:network-type tag
+var NetworkTypes = []string{"single-tenant", "multi-tenant", "disconnected"}
+
+// Tags the parsed vcm tags of a vlan or subnet
+type Tags struct {
+	// Vlan is zero unless tagged
+	Vlan        int
+	Role        string
+	NetworkType string
+	Exclude     bool
+
+	// Legacy is set if the vlan was tagged with pri_<vlan>
+	Legacy bool
+}
+
+// VlanTag returns the tag associating a subnet with the vlan
+func VlanTag(vlanNumber int) string {
+	return fmt.Sprintf("%s=%d", TagVlan, vlanNumber)
+}
+
+// LegacyVlanTag returns the pri_<vlan> tag associating a subnet with the vlan
+func LegacyVlanTag(vlanNumber int) string {
+	return fmt.Sprintf("pri_%d", vlanNumber)
+}
+
+// TagNames returns the names of the tag references
+func TagNames(refs []datatypes.Tag_Reference) []string {
+	names := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if r.Tag != nil && r.Tag.Name != nil {
+			names = append(names, *r.Tag.Name)
+		}
+	}
+	return names
+}
+
+// ParseTags parses the vcm tags in names. An error is returned for unknown vcm keys,
+// invalid values and conflicting values of the same key.
+func ParseTags(names []string) (Tags, error) {
+	var tags Tags
+
+	set := func(key string, current *string, value string) error {
+		if *current != "" && *current != value {
+			return fmt.Errorf("conflicting %s tags %s and %s", key, *current, value)
+		}
+		*current = value
+		return nil
+	}
+
+	legacyVlan := 0
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if m := legacyVlanTag.FindStringSubmatch(name); m != nil {
+			vlan, err := parseVlanNumber(m[1])
+			if err != nil {
+				return tags, fmt.Errorf("tag %s: %w", name, err)
+			}
+			if legacyVlan != 0 && legacyVlan != vlan {
+				return tags, fmt.Errorf("conflicting tags %s and %s", LegacyVlanTag(legacyVlan), name)
+			}
+			legacyVlan = vlan
+			continue
+		}
+
+		if !strings.HasPrefix(strings.ToLower(name), TagPrefix) {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(name, "=")
+		key = strings.ToLower(key)
+		switch key {
+		case TagVlan:
+			vlan, err := parseVlanNumber(value)
+			if err != nil {
+				return tags, fmt.Errorf("tag %s: %w", name, err)
+			}
+			if tags.Vlan != 0 && tags.Vlan != vlan {
+				return tags, fmt.Errorf("conflicting tags %s and %s", VlanTag(tags.Vlan), name)
+			}
+			tags.Vlan = vlan
+		case TagRole:
+			value = strings.ToLower(value)
+			if value != SubnetRoleIPv4 && value != SubnetRoleIPv6 {
+				return tags, fmt.Errorf("tag %s: role must be %s or %s", name, SubnetRoleIPv4, SubnetRoleIPv6)
+			}
+			if err := set(TagRole, &tags.Role, value); err != nil {
+				return tags, err
+			}
+		case TagNetworkType:
+			value = strings.ToLower(value)
+			if !slices.Contains(NetworkTypes, value) {
+				return tags, fmt.Errorf("tag %s: network type must be one of %s", name, strings.Join(NetworkTypes, ", "))
+			}
+			if err := set(TagNetworkType, &tags.NetworkType, value); err != nil {
+				return tags, err
+			}
+		case TagExclude:
+			if hasValue {
+				return tags, fmt.Errorf("tag %s: %s does not take a value", name, TagExclude)
+			}
+			tags.Exclude = true
+		default:
+			return tags, fmt.Errorf("unknown tag %s", name)
+		}
+	}
+
+	if legacyVlan != 0 {
+		if tags.Vlan != 0 && tags.Vlan != legacyVlan {
+			return tags, fmt.Errorf("conflicting tags %s and %s", VlanTag(tags.Vlan), LegacyVlanTag(legacyVlan))
+		}
+		if tags.Vlan == 0 {
+			tags.Vlan = legacyVlan
+			tags.Legacy = true
+		}
+	}
+
+	return tags, nil
+}
+
+// SubnetRole returns the tagged role of the subnet or, if untagged, the role of its version
+func SubnetRole(subnet datatypes.Network_Subnet, tags Tags) string {
+	if tags.Role != "" {
+		return tags.Role
+	}
+	if subnet.Version != nil && *subnet.Version == 6 {
+		return SubnetRoleIPv6
+	}
+	return SubnetRoleIPv4
+}
+
+func parseVlanNumber(s string) (int, error) {
+	vlan, err := strconv.Atoi(s)
+	if err != nil || vlan < 1 || vlan > 4094 {
+		return 0, fmt.Errorf("vlan must be a number between 1 and 4094")
+	}
+	return vlan, nil
+}
@@ -0,0 +1,86 @@
+// Package ibmcloudtest fakes the SoftLayer API in tests
+package ibmcloudtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/softlayer/softlayer-go/session"
+	"github.com/softlayer/softlayer-go/sl"
+)
+
+// FakeRequest a request received by a FakeTransport
+type FakeRequest struct {
+	Service string
+	Method  string
+	Args    []interface{}
+	Mask    string
+	Filter  string
+}
+
+// FakeTransport is a session.TransportHandler returning canned json responses, set as
+// the Transport of an ibmcloud.Metadata it allows tests without an account. Responses are
+// keyed by service and method, e.g. SoftLayer_Account::getNetworkVlans. Array
+// responses are paged with the limit and offset of the request.
+type FakeTransport struct {
+	Responses map[string]string
+
+	mu       sync.Mutex
+	Requests []FakeRequest
+}
+
+// NewFakeTransport creates a transport with the responses and a valid current user
+func NewFakeTransport(responses map[string]string) *FakeTransport {
+	t := &FakeTransport{
+		Responses: map[string]string{
+			"SoftLayer_Account::getCurrentUser": `{"id":1,"username":"fake"}`,
+		},
+	}
+	for k, v := range responses {
+		t.Responses[k] = v
+	}
+	return t
+}
+
+// DoRequest implements session.TransportHandler
+func (t *FakeTransport) DoRequest(sess *session.Session, service, method string, args []interface{}, options *sl.Options, pResult interface{}) error {
+	req := FakeRequest{Service: service, Method: method, Args: args}
+	if options != nil {
+		req.Mask = options.Mask
+		req.Filter = options.Filter
+	}
+
+	t.mu.Lock()
+	t.Requests = append(t.Requests, req)
+	t.mu.Unlock()
+
+	response, ok := t.Responses[service+"::"+method]
+	if !ok {
+		return sl.Error{
+			StatusCode: 404,
+			Exception:  "SoftLayer_Exception_MethodNotFound",
+			Message:    fmt.Sprintf("no fake response for %s::%s", service, method),
+		}
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(response), &items); err != nil || options == nil {
+		return json.Unmarshal([]byte(response), pResult)
+	}
+
+	offset := 0
+	if options.Offset != nil {
+		offset = min(*options.Offset, len(items))
+	}
+	end := len(items)
+	if options.Limit != nil {
+		end = min(offset+*options.Limit, len(items))
+	}
+
+	page, err := json.Marshal(items[offset:end])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(page, pResult)
+}
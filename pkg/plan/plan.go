@@ -0,0 +1,57 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+// Action a single change of a plan
+type Action struct {
+	Description string
+	Apply       func() error
+}
+
+// Plan an ordered list of changes that is shown before it is applied
+type Plan struct {
+	Actions []Action
+}
+
+// Add appends an action to the plan
+func (p *Plan) Add(description string, apply func() error) {
+	p.Actions = append(p.Actions, Action{Description: description, Apply: apply})
+}
+
+// Empty reports if the plan has no actions
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Write writes the description of every action to w
+func (p *Plan) Write(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Plan: %d change(s)\n", len(p.Actions)); err != nil {
+		return err
+	}
+	for _, a := range p.Actions {
+		if _, err := fmt.Fprintf(w, "  + %s\n", a.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply applies the actions in order and stops at the first failure
+func (p *Plan) Apply() error {
+	for i, a := range p.Actions {
+		if err := a.Apply(); err != nil {
+			return fmt.Errorf("%s: %w (%d of %d changes applied)", a.Description, err, i, len(p.Actions))
+		}
+		log.Printf("applied: %s", a.Description)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	vimsession "github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const userAgent = "vcmd"
+
+// cachedSession the session cookies of a vCenter persisted between runs
+type cachedSession struct {
+	Server        string         `json:"server"`
+	Username      string         `json:"username"`
+	Cookies       []*http.Cookie `json:"cookies"`
+	RESTSessionID string         `json:"restSessionId"`
+}
+
+// NormalizeThumbprint returns the upper case hex SHA-256 thumbprint without separators
+func NormalizeThumbprint(thumbprint string) (string, error) {
+	tp := strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(thumbprint))
+	if b, err := hex.DecodeString(tp); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("thumbprint %q is not a SHA-256 fingerprint", thumbprint)
+	}
+	return tp, nil
+}
+
+// ThumbprintSHA256 returns the SHA-256 thumbprint of the certificate in the NormalizeThumbprint format
+func ThumbprintSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// newSoapClient creates the soap client of the vCenter. The certificate is verified with the CA
+// bundle, and its thumbprint is compared with the pinned one. Without either the certificate
+// is not verified and a warning is logged.
+func newSoapClient(server string, credential VCenterCredential) (*soap.Client, error) {
+	// soap.ParseURL fails on bare IPv6 addresses
+	urlSafeServer := server
+	if ip, err := netip.ParseAddr(server); err == nil && ip.Is6() {
+		urlSafeServer = fmt.Sprintf("[%s]", server)
+	}
+	u, err := soap.ParseURL(urlSafeServer)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, fmt.Errorf("unable to parse vCenter url %s", server)
+	}
+
+	insecure := credential.CAFile == ""
+	if insecure && credential.Thumbprint == "" {
+		log.Printf("WARNING: the certificate of vCenter %s is not verified, set a CA file or thumbprint", server)
+	}
+
+	c := soap.NewClient(u, insecure)
+	c.UserAgent = userAgent
+
+	transport := c.DefaultTransport()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = new(tls.Config)
+	}
+
+	if credential.CAFile != "" {
+		if err := c.SetRootCAs(credential.CAFile); err != nil {
+			return nil, fmt.Errorf("unable to read the CA file of vCenter %s: %w", server, err)
+		}
+	}
+
+	if credential.Thumbprint != "" {
+		pinned, err := NormalizeThumbprint(credential.Thumbprint)
+		if err != nil {
+			return nil, fmt.Errorf("vCenter %s: %w", server, err)
+		}
+		transport.TLSClientConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("vCenter %s presented no certificate", server)
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if tp := ThumbprintSHA256(cert); tp != pinned {
+				return fmt.Errorf("vCenter %s certificate thumbprint %s does not match the pinned thumbprint", server, tp)
+			}
+			return nil
+		}
+	}
+
+	return c, nil
+}
+
+// newSession logs in to the vCenter, or reuses the session cookies of a previous run when the
+// session cache is enabled and they are still valid.
+func (m *Metadata) newSession(ctx context.Context, server string, credential VCenterCredential) (*session.Session, error) {
+	soapClient, err := newSoapClient(server, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to vCenter %s: %w", server, err)
+	}
+	vimClient.UserAgent = userAgent
+
+	var soapKeepAlive *keepalive.HandlerSOAP
+	if m.KeepAlive > 0 {
+		soapKeepAlive = keepalive.NewHandlerSOAP(vimClient.RoundTripper, m.KeepAlive, nil)
+		vimClient.RoundTripper = soapKeepAlive
+	}
+
+	c := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: vimsession.NewManager(vimClient),
+	}
+
+	rc := rest.NewClient(vimClient)
+	var restKeepAlive *keepalive.HandlerREST
+	if m.KeepAlive > 0 {
+		restKeepAlive = keepalive.NewHandlerREST(rc, m.KeepAlive, nil)
+		rc.Transport = restKeepAlive
+	}
+
+	user := url.UserPassword(credential.Username, credential.Password)
+	cacheFile := m.sessionCacheFile(server, credential.Username)
+
+	restored := m.restoreSession(ctx, cacheFile, server, credential.Username, soapClient, c, rc)
+	if !restored {
+		if err := c.Login(ctx, user); err != nil {
+			return nil, fmt.Errorf("unable to login to vCenter %s: %w", server, err)
+		}
+		if err := rc.Login(ctx, user); err != nil {
+			if errLogout := c.Logout(ctx); errLogout != nil {
+				log.Printf("WARNING: unable to logout of vCenter %s: %v", server, errLogout)
+			}
+			return nil, fmt.Errorf("unable to login to the vCenter %s REST api: %w", server, err)
+		}
+		m.saveSession(cacheFile, server, credential.Username, soapClient, rc)
+	}
+
+	// the keep alive handlers only start on a login request
+	if restored {
+		if soapKeepAlive != nil {
+			soapKeepAlive.Start()
+		}
+		if restKeepAlive != nil {
+			restKeepAlive.Start()
+		}
+	}
+	var stops []func()
+	if soapKeepAlive != nil {
+		stops = append(stops, soapKeepAlive.Stop)
+	}
+	if restKeepAlive != nil {
+		stops = append(stops, restKeepAlive.Stop)
+	}
+	m.keepAliveStops[server] = stops
+
+	return &session.Session{
+		Client:     c,
+		Finder:     find.NewFinder(vimClient, false),
+		TagManager: tags.NewManager(rc),
+	}, nil
+}
+
+// sessionCacheFile returns the session cache file of the vCenter and user, empty if the cache is disabled
+func (m *Metadata) sessionCacheFile(server, username string) string {
+	if m.SessionCacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(server + "#" + username))
+	return filepath.Join(m.SessionCacheDir, fmt.Sprintf("vcenter-%x.json", sum[:8]))
+}
+
+// restoreSession sets the cached cookies of the vCenter and user on the clients and reports if
+// both sessions are still authenticated
+func (m *Metadata) restoreSession(ctx context.Context, cacheFile, server, username string, soapClient *soap.Client, c *govmomi.Client, rc *rest.Client) bool {
+	if cacheFile == "" {
+		return false
+	}
+
+	b, err := os.ReadFile(cacheFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("WARNING: unable to read the session cache: %v", err)
+		}
+		return false
+	}
+
+	var cached cachedSession
+	if err := json.Unmarshal(b, &cached); err != nil {
+		log.Printf("WARNING: ignoring the invalid session cache %s: %v", cacheFile, err)
+		return false
+	}
+	if cached.Server != server || cached.Username != username || len(cached.Cookies) == 0 || cached.RESTSessionID == "" {
+		log.Printf("WARNING: ignoring the session cache %s, it is not a session of %s on %s", cacheFile, username, server)
+		return false
+	}
+
+	soapClient.Jar.SetCookies(soapClient.URL(), cached.Cookies)
+	rc.SessionID(cached.RESTSessionID)
+
+	if userSession, err := c.SessionManager.UserSession(ctx); err != nil || userSession == nil {
+		return false
+	}
+	if restSession, err := rc.Session(ctx); err != nil || restSession == nil {
+		return false
+	}
+
+	return true
+}
+
+// saveSession persists the session cookies, readable only by the user
+func (m *Metadata) saveSession(cacheFile, server, username string, soapClient *soap.Client, rc *rest.Client) {
+	if cacheFile == "" {
+		return
+	}
+
+	b, err := json.Marshal(cachedSession{
+		Server:        server,
+		Username:      username,
+		Cookies:       soapClient.Jar.Cookies(soapClient.URL()),
+		RESTSessionID: rc.SessionID(),
+	})
+	if err != nil {
+		log.Printf("WARNING: unable to cache the session of %s: %v", server, err)
+		return
+	}
+
+	if err := os.MkdirAll(m.SessionCacheDir, 0o700); err != nil {
+		log.Printf("WARNING: unable to cache the session of %s: %v", server, err)
+		return
+	}
+	if err := os.WriteFile(cacheFile, b, 0o600); err != nil {
+		log.Printf("WARNING: unable to cache the session of %s: %v", server, err)
+	}
+}
+
+// Logout ends the sessions of every vCenter. Sessions are kept open for reuse when the session
+// cache is enabled, only their keep alive is stopped.
+func (m *Metadata) Logout() {
+	for server, sess := range m.sessions {
+		if m.SessionCacheDir == "" {
+			ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+			if err := sess.TagManager.Logout(ctx); err != nil {
+				log.Printf("WARNING: unable to logout of the vCenter %s REST api: %v", server, err)
+			}
+			if err := sess.Logout(ctx); err != nil {
+				log.Printf("WARNING: unable to logout of vCenter %s: %v", server, err)
+			}
+			cancel()
+		}
+
+		for _, stop := range m.keepAliveStops[server] {
+			stop()
+		}
+		delete(m.keepAliveStops, server)
+		delete(m.sessions, server)
+	}
+}
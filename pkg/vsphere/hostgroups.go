@@ -0,0 +1,239 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+// HostGroupZone the zone tagged hosts of a cluster and the DRS groups placing virtual machines on them
+type HostGroupZone struct {
+	Server  string
+	Cluster string
+	Zone    string
+
+	// Hosts the inventory paths of the zone tagged hosts
+	Hosts    []string
+	hostRefs []types.ManagedObjectReference
+
+	HostGroup string
+	VMGroup   string
+	Rule      string
+
+	// HostGroupExists the host group exists, HostGroupInSync it has exactly the zone tagged hosts
+	HostGroupExists bool
+	HostGroupInSync bool
+	VMGroupExists   bool
+	RuleExists      bool
+}
+
+// default DRS group and rule names of a zone without existing groups
+func defaultHostGroupName(zone string) string { return zone + "-host-group" }
+func defaultVMGroupName(zone string) string   { return zone + "-vm-group" }
+func defaultRuleName(zone string) string      { return zone + "-vm-host-rule" }
+
+// HostGroupZone returns the host group zone of a failure domain read by GetFailureDomainsViaTag
+func (m *Metadata) HostGroupZone(failureDomain string) (HostGroupZone, bool) {
+	hgz, ok := m.hostGroupZones[failureDomain]
+	return hgz, ok
+}
+
+// hostZones returns the zone tagged hosts of the cluster by zone
+func hostZones(scopes []taggedScope, clusterPath string) map[string][]taggedScope {
+	zones := make(map[string][]taggedScope)
+	for _, s := range scopes {
+		if s.ref.Type == hostType && scopeContains(clusterPath, s.path) && s.path != clusterPath {
+			zones[s.tag] = append(zones[s.tag], s)
+		}
+	}
+	return zones
+}
+
+// drsHostGroupZones matches the zones of the cluster with its DRS groups. A host group with exactly
+// the hosts of a zone is used, otherwise the default names are. The VM group and rule are taken
+// from a VM/Host rule affine to the host group.
+func drsHostGroupZones(ctx context.Context, clusterObj *object.ClusterComputeResource, server string, zoneHosts map[string][]taggedScope) (map[string]HostGroupZone, error) {
+	var cMo mo.ClusterComputeResource
+	if err := clusterObj.Properties(ctx, clusterObj.Reference(), []string{"configurationEx"}, &cMo); err != nil {
+		return nil, err
+	}
+
+	hostGroups := make(map[string][]types.ManagedObjectReference)
+	vmGroups := make(map[string]bool)
+	var rules []*types.ClusterVmHostRuleInfo
+	if config, ok := cMo.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+		for _, g := range config.Group {
+			switch group := g.(type) {
+			case *types.ClusterHostGroup:
+				hostGroups[group.Name] = group.Host
+			case *types.ClusterVmGroup:
+				vmGroups[group.Name] = true
+			}
+		}
+		for _, r := range config.Rule {
+			if rule, ok := r.(*types.ClusterVmHostRuleInfo); ok {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	zones := make(map[string]HostGroupZone, len(zoneHosts))
+	for zone, hosts := range zoneHosts {
+		hgz := HostGroupZone{
+			Server:    server,
+			Cluster:   clusterObj.InventoryPath,
+			Zone:      zone,
+			HostGroup: defaultHostGroupName(zone),
+			VMGroup:   defaultVMGroupName(zone),
+			Rule:      defaultRuleName(zone),
+		}
+		for _, h := range hosts {
+			hgz.Hosts = append(hgz.Hosts, h.path)
+			hgz.hostRefs = append(hgz.hostRefs, h.ref)
+		}
+		sort.Strings(hgz.Hosts)
+
+		names := make([]string, 0, len(hostGroups))
+		for name := range hostGroups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sameHosts(hostGroups[name], hgz.hostRefs) {
+				hgz.HostGroup = name
+				break
+			}
+		}
+		if refs, ok := hostGroups[hgz.HostGroup]; ok {
+			hgz.HostGroupExists = true
+			hgz.HostGroupInSync = sameHosts(refs, hgz.hostRefs)
+		}
+
+		for _, rule := range rules {
+			if rule.AffineHostGroupName == hgz.HostGroup && rule.VmGroupName != "" {
+				hgz.VMGroup = rule.VmGroupName
+				hgz.Rule = rule.Name
+				hgz.RuleExists = true
+				break
+			}
+		}
+		hgz.VMGroupExists = vmGroups[hgz.VMGroup]
+
+		zones[zone] = hgz
+	}
+
+	return zones, nil
+}
+
+// sameHosts reports if both lists hold the same hosts
+func sameHosts(a, b []types.ManagedObjectReference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ref := range a {
+		if !slices.Contains(b, ref) {
+			return false
+		}
+	}
+	return true
+}
+
+// PlanDRSGroups adds the missing DRS host groups, VM groups and VM/Host rules of the host group zones
+// of the server to the plan. Host groups that exist with other hosts are updated. The rules are
+// mandatory ("must run on") or preferential ("should run on").
+func (m *Metadata) PlanDRSGroups(p *plan.Plan, server string, mandatory bool) error {
+	if _, err := m.GetFailureDomainsViaTag(server); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(m.hostGroupZones))
+	for name, hgz := range m.hostGroupZones {
+		if hgz.Server == server {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hgz := m.hostGroupZones[name]
+
+		if !hgz.HostGroupInSync {
+			operation := types.ArrayUpdateOperationAdd
+			verb := "create"
+			if hgz.HostGroupExists {
+				operation = types.ArrayUpdateOperationEdit
+				verb = "update"
+			}
+			spec := &types.ClusterConfigSpecEx{
+				GroupSpec: []types.ClusterGroupSpec{{
+					ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: operation},
+					Info: &types.ClusterHostGroup{
+						ClusterGroupInfo: types.ClusterGroupInfo{Name: hgz.HostGroup},
+						Host:             hgz.hostRefs,
+					},
+				}},
+			}
+			p.Add(fmt.Sprintf("%s: %s host group %s of zone %s in cluster %s with %d host(s)", server, verb, hgz.HostGroup, hgz.Zone, hgz.Cluster, len(hgz.Hosts)), func() error {
+				return m.reconfigureCluster(server, hgz.Cluster, spec)
+			})
+		}
+		if !hgz.VMGroupExists {
+			spec := &types.ClusterConfigSpecEx{
+				GroupSpec: []types.ClusterGroupSpec{{
+					ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+					Info: &types.ClusterVmGroup{
+						ClusterGroupInfo: types.ClusterGroupInfo{Name: hgz.VMGroup},
+					},
+				}},
+			}
+			p.Add(fmt.Sprintf("%s: create vm group %s of zone %s in cluster %s", server, hgz.VMGroup, hgz.Zone, hgz.Cluster), func() error {
+				return m.reconfigureCluster(server, hgz.Cluster, spec)
+			})
+		}
+		if !hgz.RuleExists {
+			enabled := true
+			spec := &types.ClusterConfigSpecEx{
+				RulesSpec: []types.ClusterRuleSpec{{
+					ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+					Info: &types.ClusterVmHostRuleInfo{
+						ClusterRuleInfo: types.ClusterRuleInfo{
+							Name:      hgz.Rule,
+							Enabled:   &enabled,
+							Mandatory: &mandatory,
+						},
+						VmGroupName:         hgz.VMGroup,
+						AffineHostGroupName: hgz.HostGroup,
+					},
+				}},
+			}
+			p.Add(fmt.Sprintf("%s: create rule %s placing vm group %s on host group %s in cluster %s", server, hgz.Rule, hgz.VMGroup, hgz.HostGroup, hgz.Cluster), func() error {
+				return m.reconfigureCluster(server, hgz.Cluster, spec)
+			})
+		}
+	}
+
+	return nil
+}
+
+// reconfigureCluster applies the incremental cluster spec and waits for the task
+func (m *Metadata) reconfigureCluster(server, clusterPath string, spec *types.ClusterConfigSpecEx) error {
+	cluster, err := m.GetClusterByPath(server, clusterPath)
+	if err != nil {
+		return err
+	}
+
+	return withTimeout(func(ctx context.Context) error {
+		task, err := cluster.Reconfigure(ctx, spec, true)
+		if err != nil {
+			return err
+		}
+		return task.Wait(ctx)
+	})
+}
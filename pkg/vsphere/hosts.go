@@ -0,0 +1,94 @@
+package vsphere
+
+import (
+	"context"
+	"net"
+	"path"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// HostNic a physical nic of an ESXi host, SpeedMb is zero if the link is down
+type HostNic struct {
+	Device  string `json:"device"`
+	SpeedMb int32  `json:"speedMb"`
+}
+
+// Host the hardware summary of an ESXi host
+type Host struct {
+	Name          string    `json:"name"`
+	InventoryPath string    `json:"inventoryPath"`
+	ManagementIPs []net.IP  `json:"managementIPs"`
+	Vendor        string    `json:"vendor"`
+	Model         string    `json:"model"`
+	CpuModel      string    `json:"cpuModel"`
+	NumCpuPkgs    int16     `json:"numCpuPkgs"`
+	NumCpuCores   int16     `json:"numCpuCores"`
+	MemorySize    int64     `json:"memorySize"`
+	Nics          []HostNic `json:"nics"`
+}
+
+// GetClusterHosts returns the hardware summary and management addresses of the hosts in the cluster
+func (m *Metadata) GetClusterHosts(server string, cluster *object.ClusterComputeResource) ([]Host, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var cMo mo.ClusterComputeResource
+	if err := cluster.Properties(ctx, cluster.Reference(), []string{"host"}, &cMo); err != nil {
+		return nil, err
+	}
+	if len(cMo.Host) == 0 {
+		return nil, nil
+	}
+
+	var hostMos []mo.HostSystem
+	err = sess.Retrieve(ctx, cMo.Host, []string{"name", "summary.hardware", "config.network.vnic", "config.network.pnic"}, &hostMos)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]Host, 0, len(hostMos))
+	for _, h := range hostMos {
+		host := Host{
+			Name:          h.Name,
+			InventoryPath: path.Join(cluster.InventoryPath, h.Name),
+		}
+
+		if hw := h.Summary.Hardware; hw != nil {
+			host.Vendor = hw.Vendor
+			host.Model = hw.Model
+			host.CpuModel = hw.CpuModel
+			host.NumCpuPkgs = hw.NumCpuPkgs
+			host.NumCpuCores = hw.NumCpuCores
+			host.MemorySize = hw.MemorySize
+		}
+
+		if h.Config != nil && h.Config.Network != nil {
+			var addresses []string
+			for _, vnic := range h.Config.Network.Vnic {
+				if vnic.Spec.Ip != nil {
+					addresses = append(addresses, vnic.Spec.Ip.IpAddress)
+				}
+			}
+			host.ManagementIPs = parseIPAddresses(addresses...)
+
+			for _, pnic := range h.Config.Network.Pnic {
+				nic := HostNic{Device: pnic.Device}
+				if pnic.LinkSpeed != nil {
+					nic.SpeedMb = pnic.LinkSpeed.SpeedMb
+				}
+				host.Nics = append(host.Nics, nic)
+			}
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,149 @@
+package vsphere
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RegionType the kind of object region tags are attached to
+type RegionType string
+
+const (
+	// RegionTypeDatacenter a region tag on a datacenter
+	RegionTypeDatacenter RegionType = "Datacenter"
+	// RegionTypeFolder a region tag on the root folder or a datacenter folder, covering every datacenter below it
+	RegionTypeFolder RegionType = "Folder"
+	// RegionTypeComputeCluster a region tag on a cluster, used with host group zones
+	RegionTypeComputeCluster RegionType = "ComputeCluster"
+)
+
+// ZoneType the kind of object zone tags are attached to
+type ZoneType string
+
+const (
+	// ZoneTypeComputeCluster a zone tag on a cluster
+	ZoneTypeComputeCluster ZoneType = "ComputeCluster"
+	// ZoneTypeHostFolder a zone tag on a folder of a datacenter's host folder, covering every cluster below it
+	ZoneTypeHostFolder ZoneType = "HostFolder"
+	// ZoneTypeHostGroup zone tags on the hosts of a cluster, each zone of a cluster is a failure domain
+	ZoneTypeHostGroup ZoneType = "HostGroup"
+)
+
+// managed object types of the tagged objects
+const (
+	datacenterType = "Datacenter"
+	clusterType    = "ClusterComputeResource"
+	folderType     = "Folder"
+	hostType       = "HostSystem"
+)
+
+var regionObjectTypes = map[RegionType]string{
+	RegionTypeDatacenter:     datacenterType,
+	RegionTypeFolder:         folderType,
+	RegionTypeComputeCluster: clusterType,
+}
+
+var zoneObjectTypes = map[ZoneType]string{
+	ZoneTypeComputeCluster: clusterType,
+	ZoneTypeHostFolder:     folderType,
+	ZoneTypeHostGroup:      hostType,
+}
+
+// TagLayout the tag categories and the kinds of objects failure domains are read from
+type TagLayout struct {
+	RegionCategory string
+	ZoneCategory   string
+	RegionTypes    []RegionType
+	ZoneTypes      []ZoneType
+}
+
+// DefaultTagLayout regions are datacenters tagged openshift-region and zones are clusters tagged openshift-zone
+func DefaultTagLayout() TagLayout {
+	return TagLayout{
+		RegionCategory: openshiftRegionTagCatName,
+		ZoneCategory:   openshiftZoneTagCatName,
+		RegionTypes:    []RegionType{RegionTypeDatacenter},
+		ZoneTypes:      []ZoneType{ZoneTypeComputeCluster},
+	}
+}
+
+// NewTagLayout validates the layout, empty values keep the default
+func NewTagLayout(regionCategory, zoneCategory string, regionTypes, zoneTypes []string) (TagLayout, error) {
+	layout := DefaultTagLayout()
+
+	if regionCategory != "" {
+		layout.RegionCategory = regionCategory
+	}
+	if zoneCategory != "" {
+		layout.ZoneCategory = zoneCategory
+	}
+	if layout.RegionCategory == layout.ZoneCategory {
+		return layout, fmt.Errorf("the region and zone tag categories must differ")
+	}
+
+	if len(regionTypes) > 0 {
+		layout.RegionTypes = nil
+		for _, t := range regionTypes {
+			if _, ok := regionObjectTypes[RegionType(t)]; !ok {
+				return layout, fmt.Errorf("unknown region type %s, must be one of %s", t, strings.Join(mapKeys(regionObjectTypes), ", "))
+			}
+			layout.RegionTypes = append(layout.RegionTypes, RegionType(t))
+		}
+	}
+	if len(zoneTypes) > 0 {
+		layout.ZoneTypes = nil
+		for _, t := range zoneTypes {
+			if _, ok := zoneObjectTypes[ZoneType(t)]; !ok {
+				return layout, fmt.Errorf("unknown zone type %s, must be one of %s", t, strings.Join(mapKeys(zoneObjectTypes), ", "))
+			}
+			layout.ZoneTypes = append(layout.ZoneTypes, ZoneType(t))
+		}
+	}
+
+	return layout, nil
+}
+
+// regionObjectType reports if region tags on objects of the managed object type are read
+func (l TagLayout) regionObjectType(moType string) bool {
+	for _, t := range l.RegionTypes {
+		if regionObjectTypes[t] == moType {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneType returns the zone type of tags on objects of the managed object type
+func (l TagLayout) zoneType(moType string) (ZoneType, bool) {
+	for _, t := range l.ZoneTypes {
+		if zoneObjectTypes[t] == moType {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// RegionTypeNames returns the valid region types
+func RegionTypeNames() []string {
+	return mapKeys(regionObjectTypes)
+}
+
+// ZoneTypeNames returns the valid zone types
+func ZoneTypeNames() []string {
+	return mapKeys(zoneObjectTypes)
+}
+
+func mapKeys[K ~string, V any](m map[K]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scopeContains reports if the inventory path is the scope or below it, the root folder contains everything
+func scopeContains(scope, path string) bool {
+	return scope == "/" || path == scope || strings.HasPrefix(path, scope+"/")
+}
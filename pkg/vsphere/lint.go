@@ -0,0 +1,231 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const (
+	// RuleMissingCategory a region or zone category does not exist
+	RuleMissingCategory = "missing-category"
+	// RuleCategoryCardinality a region or zone category allows several tags per object
+	RuleCategoryCardinality = "category-cardinality"
+	// RuleRegionUnsupportedObject a region tag is attached to an object type not in the region types
+	RuleRegionUnsupportedObject = "region-unsupported-object"
+	// RuleZoneUnsupportedObject a zone tag is attached to an object type not in the zone types
+	RuleZoneUnsupportedObject = "zone-unsupported-object"
+	// RuleSeveralRegions an object has more than one region tag
+	RuleSeveralRegions = "several-regions"
+	// RuleSeveralZones an object has more than one zone tag
+	RuleSeveralZones = "several-zones"
+	// RuleZoneOutsideRegion a zone tagged object is not in a region tagged object
+	RuleZoneOutsideRegion = "zone-outside-region"
+	// RuleZoneInSeveralRegions the same zone name is used in more than one region
+	RuleZoneInSeveralRegions = "zone-in-several-regions"
+)
+
+// TagViolation a region or zone tag that breaks an assumption of the failure domain generation
+type TagViolation struct {
+	Server string `json:"server"`
+	Rule   string `json:"rule"`
+	Object string `json:"object"`
+	Detail string `json:"detail"`
+}
+
+// taggedObject an object with tags of a single category
+type taggedObject struct {
+	ref  types.ManagedObjectReference
+	path string
+	tags []string
+}
+
+// LintTags checks the region and zone tags of the server against the TagLayout. Tags are
+// always read from the vCenter, the cache is not used.
+func (m *Metadata) LintTags(server string) ([]TagViolation, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []TagViolation
+	violation := func(rule, object, format string, args ...any) {
+		violations = append(violations, TagViolation{Server: server, Rule: rule, Object: object, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	categories, err := sess.TagManager.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make(map[string][]taggedObject)
+	for _, name := range []string{m.TagLayout.RegionCategory, m.TagLayout.ZoneCategory} {
+		var category *tags.Category
+		for i := range categories {
+			if categories[i].Name == name {
+				category = &categories[i]
+			}
+		}
+		if category == nil {
+			violation(RuleMissingCategory, "", "tag category %s does not exist", name)
+			continue
+		}
+		if category.Cardinality != categoryCardinalitySingle {
+			violation(RuleCategoryCardinality, "", "tag category %s has %s cardinality instead of %s", name, category.Cardinality, categoryCardinalitySingle)
+		}
+
+		tagged[name], err = taggedObjects(ctx, sess, category.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// region tagged objects by inventory path
+	regions := make(map[string]string)
+	for _, o := range tagged[m.TagLayout.RegionCategory] {
+		if !m.TagLayout.regionObjectType(o.ref.Type) {
+			violation(RuleRegionUnsupportedObject, o.path, "%s is a %s tagged with region %s", o.path, o.ref.Type, strings.Join(o.tags, ", "))
+			continue
+		}
+		if len(o.tags) > 1 {
+			violation(RuleSeveralRegions, o.path, "tagged with regions %s, only one is used", strings.Join(o.tags, ", "))
+		}
+		regions[o.path] = o.tags[0]
+	}
+
+	zoneRegions := make(map[string]map[string][]string)
+	for _, o := range tagged[m.TagLayout.ZoneCategory] {
+		if _, ok := m.TagLayout.zoneType(o.ref.Type); !ok {
+			violation(RuleZoneUnsupportedObject, o.path, "%s is a %s tagged with zone %s", o.path, o.ref.Type, strings.Join(o.tags, ", "))
+			continue
+		}
+		if len(o.tags) > 1 {
+			violation(RuleSeveralZones, o.path, "tagged with zones %s, only one is used", strings.Join(o.tags, ", "))
+		}
+
+		region, ok := containingRegion(regions, o.path)
+		if !ok {
+			violation(RuleZoneOutsideRegion, o.path, "zone %s is not in a region tagged object", o.tags[0])
+			continue
+		}
+
+		for _, zone := range o.tags {
+			if zoneRegions[zone] == nil {
+				zoneRegions[zone] = make(map[string][]string)
+			}
+			zoneRegions[zone][region] = append(zoneRegions[zone][region], o.path)
+		}
+	}
+
+	for zone, byRegion := range zoneRegions {
+		if len(byRegion) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(byRegion))
+		for region := range byRegion {
+			names = append(names, region)
+		}
+		sort.Strings(names)
+		for _, region := range names {
+			for _, path := range byRegion[region] {
+				violation(RuleZoneInSeveralRegions, path, "zone %s is used in regions %s", zone, strings.Join(names, ", "))
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Rule != violations[j].Rule {
+			return violations[i].Rule < violations[j].Rule
+		}
+		return violations[i].Object < violations[j].Object
+	})
+
+	return violations, nil
+}
+
+// taggedObjects returns every object with a tag of the category and its tag names
+func taggedObjects(ctx context.Context, sess *session.Session, categoryID string) ([]taggedObject, error) {
+	categoryTags, err := sess.TagManager.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(categoryTags) == 0 {
+		return nil, nil
+	}
+
+	names := make(map[string]string, len(categoryTags))
+	ids := make([]string, 0, len(categoryTags))
+	for _, t := range categoryTags {
+		names[t.ID] = t.Name
+		ids = append(ids, t.ID)
+	}
+
+	attached, err := sess.TagManager.GetAttachedObjectsOnTags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byRef := make(map[types.ManagedObjectReference]*taggedObject)
+	var refs []types.ManagedObjectReference
+	for _, ao := range attached {
+		for _, r := range ao.ObjectIDs {
+			ref := r.Reference()
+			o, ok := byRef[ref]
+			if !ok {
+				path, err := find.InventoryPath(ctx, sess.Client.Client, ref)
+				if err != nil || path == "" {
+					path = ref.String()
+				}
+				o = &taggedObject{ref: ref, path: path}
+				byRef[ref] = o
+				refs = append(refs, ref)
+			}
+			o.tags = append(o.tags, names[ao.TagID])
+		}
+	}
+
+	objects := make([]taggedObject, 0, len(refs))
+	for _, ref := range refs {
+		o := byRef[ref]
+		sort.Strings(o.tags)
+		objects = append(objects, *o)
+	}
+	return objects, nil
+}
+
+// containingRegion returns the region of the closest region tagged object containing the path
+func containingRegion(regions map[string]string, path string) (string, bool) {
+	best := ""
+	found := false
+	for scope := range regions {
+		if scopeContains(scope, path) && (!found || len(scope) > len(best)) {
+			best = scope
+			found = true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	return regions[best], true
+}
+
+// WriteTagViolations writes the violations as a table
+func WriteTagViolations(w io.Writer, violations []TagViolation) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tSERVER\tOBJECT\tDETAIL")
+	for _, v := range violations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Rule, v.Server, v.Object, v.Detail)
+	}
+	return tw.Flush()
+}
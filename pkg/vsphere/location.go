@@ -0,0 +1,124 @@
+package vsphere
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+const vpxdManagedIPv4Option = "VirtualCenter.AutoManagedIPV4"
+
+// parseIPAddresses parses the addresses, skipping invalid, loopback and link-local addresses
+func parseIPAddresses(addresses ...string) []net.IP {
+	var ips []net.IP
+	for _, a := range addresses {
+		ip := net.ParseIP(strings.TrimSpace(a))
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// GetVCenterVirtualMachineIPAddresses returns the guest ip addresses of the vCenter's own
+// virtual machine, nil if the virtual machine is not in its own inventory.
+func (m *Metadata) GetVCenterVirtualMachineIPAddresses(server string) ([]net.IP, error) {
+	vm, err := m.FindVCenterVirtualMachine(server)
+	if err != nil || vm == nil || vm.Guest == nil {
+		return nil, err
+	}
+
+	addresses := []string{vm.Guest.IpAddress}
+	for _, nic := range vm.Guest.Net {
+		addresses = append(addresses, nic.IpAddress...)
+	}
+
+	return parseIPAddresses(addresses...), nil
+}
+
+// GetVpxdIPAddresses returns the addresses vpxd is configured with, the managed IPv4
+// address and the resolved addresses of the config.vpxd.hostnameUrl host.
+func (m *Metadata) GetVpxdIPAddresses(server string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+
+	optmgr := object.NewOptionManager(sess.Client.Client, *sess.ServiceContent.Setting)
+
+	// the option is not set on every vCenter, a missing option is not an error
+	if managedIP, err := optmgr.Query(ctx, vpxdManagedIPv4Option); err == nil && len(managedIP) > 0 {
+		if v, ok := managedIP[0].GetOptionValue().Value.(string); ok {
+			ips = append(ips, parseIPAddresses(v)...)
+		}
+	}
+
+	hostnameUrl, err := m.GetHostnameUrlVpxd(server)
+	if err != nil {
+		return nil, err
+	}
+
+	host := *hostnameUrl
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return append(ips, ip), nil
+	}
+
+	// an unresolvable hostname only means this source has nothing to offer
+	if resolved, err := net.LookupIP(host); err == nil {
+		ips = append(ips, resolved...)
+	}
+
+	return ips, nil
+}
+
+// GetHostManagementIPAddresses returns the VMkernel adapter addresses of every ESXi host
+func (m *Metadata) GetHostManagementIPAddresses(server string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr := view.NewManager(sess.Client.Client)
+	kind := []string{"HostSystem"}
+
+	v, err := mgr.CreateContainerView(ctx, sess.ServiceContent.RootFolder, kind, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var hosts []mo.HostSystem
+	if err := v.Retrieve(ctx, kind, []string{"config.network.vnic"}, &hosts); err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, h := range hosts {
+		if h.Config == nil || h.Config.Network == nil {
+			continue
+		}
+		for _, vnic := range h.Config.Network.Vnic {
+			if vnic.Spec.Ip != nil {
+				addresses = append(addresses, vnic.Spec.Ip.IpAddress)
+			}
+		}
+	}
+
+	return parseIPAddresses(addresses...), nil
+}
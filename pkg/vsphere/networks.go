@@ -0,0 +1,124 @@
+package vsphere
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// PortGroupVlanId returns the vlan id of a port group, false if the port group
+// is not on a single vlan, e.g. a trunk.
+func PortGroupVlanId(pg mo.DistributedVirtualPortgroup) (int32, bool) {
+	if pg.Config.DefaultPortConfig == nil {
+		return 0, false
+	}
+	portSetting, ok := pg.Config.DefaultPortConfig.(*types.VMwareDVSPortSetting)
+	if !ok || portSetting.Vlan == nil {
+		return 0, false
+	}
+	vlan, ok := portSetting.Vlan.(*types.VmwareDistributedVirtualSwitchVlanIdSpec)
+	if !ok {
+		return 0, false
+	}
+	return vlan.VlanId, true
+}
+
+// GetDistributedSwitchNames returns the names of the distributed switches by managed object id
+func (m *Metadata) GetDistributedSwitchNames(server string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr := view.NewManager(sess.Client.Client)
+	kind := []string{"DistributedVirtualSwitch"}
+
+	v, err := mgr.CreateContainerView(ctx, sess.ServiceContent.RootFolder, kind, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var switches []mo.DistributedVirtualSwitch
+	if err := v.Retrieve(ctx, kind, []string{"name"}, &switches); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(switches))
+	for _, s := range switches {
+		names[s.Self.Value] = s.Name
+	}
+	return names, nil
+}
+
+// GetClusterNetworks returns the managed object ids of the networks available in the cluster
+func (m *Metadata) GetClusterNetworks(server string, cluster *object.ClusterComputeResource) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	if _, err := m.Session(ctx, server); err != nil {
+		return nil, err
+	}
+
+	var cMo mo.ClusterComputeResource
+	if err := cluster.Properties(ctx, cluster.Reference(), []string{"network"}, &cMo); err != nil {
+		return nil, err
+	}
+
+	networks := make(map[string]bool, len(cMo.Network))
+	for _, n := range cMo.Network {
+		networks[n.Value] = true
+	}
+	return networks, nil
+}
+
+// GetClusterSwitches returns the managed object ids of the distributed switches a host of the cluster is a member of
+func (m *Metadata) GetClusterSwitches(server string, cluster *object.ClusterComputeResource) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var cMo mo.ClusterComputeResource
+	if err := cluster.Properties(ctx, cluster.Reference(), []string{"host"}, &cMo); err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]bool, len(cMo.Host))
+	for _, h := range cMo.Host {
+		hosts[h.Value] = true
+	}
+
+	mgr := view.NewManager(sess.Client.Client)
+	kind := []string{"DistributedVirtualSwitch"}
+
+	v, err := mgr.CreateContainerView(ctx, sess.ServiceContent.RootFolder, kind, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var switches []mo.DistributedVirtualSwitch
+	if err := v.Retrieve(ctx, kind, []string{"summary.hostMember"}, &switches); err != nil {
+		return nil, err
+	}
+
+	clusterSwitches := make(map[string]bool)
+	for _, s := range switches {
+		for _, h := range s.Summary.HostMember {
+			if hosts[h.Value] {
+				clusterSwitches[s.Self.Value] = true
+				break
+			}
+		}
+	}
+	return clusterSwitches, nil
+}
@@ -2,11 +2,16 @@ package vsphere
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"path"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25/types"
 
@@ -23,7 +28,8 @@ const (
 	openshiftRegionTagCatName = "openshift-region"
 )
 
-func (m *Metadata) FindVCenterVirtualMachine(server string) (*mo.VirtualMachine, error) {
+// GetVirtualMachines retrieves the config, guest and network properties of every virtual machine
+func (m *Metadata) GetVirtualMachines(server string) ([]mo.VirtualMachine, error) {
 	sess, err := m.Session(context.TODO(), server)
 	if err != nil {
 		return nil, err
@@ -36,9 +42,19 @@ func (m *Metadata) FindVCenterVirtualMachine(server string) (*mo.VirtualMachine,
 	if err != nil {
 		return nil, err
 	}
+	defer v.Destroy(context.TODO())
 
 	var virtualMachines []mo.VirtualMachine
-	err = v.Retrieve(context.TODO(), kind, []string{"config", "guest", "network"}, &virtualMachines)
+	err = v.Retrieve(context.TODO(), kind, []string{"name", "config", "guest", "network"}, &virtualMachines)
+	if err != nil {
+		return nil, err
+	}
+
+	return virtualMachines, nil
+}
+
+func (m *Metadata) FindVCenterVirtualMachine(server string) (*mo.VirtualMachine, error) {
+	virtualMachines, err := m.GetVirtualMachines(server)
 	if err != nil {
 		return nil, err
 	}
@@ -52,6 +68,41 @@ func (m *Metadata) FindVCenterVirtualMachine(server string) (*mo.VirtualMachine,
 	return nil, nil
 }
 
+// GuestNetworkAddresses contains the ip addresses a virtual machine guest reports on a single network
+type GuestNetworkAddresses struct {
+	VirtualMachine string
+	Network        string
+	IPAddresses    []net.IP
+}
+
+// GetGuestNetworkAddresses returns the guest reported ip addresses of every virtual machine
+// by network (port group) name. Loopback and link-local addresses are ignored.
+func (m *Metadata) GetGuestNetworkAddresses(server string) ([]GuestNetworkAddresses, error) {
+	virtualMachines, err := m.GetVirtualMachines(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []GuestNetworkAddresses
+	for _, vm := range virtualMachines {
+		if vm.Guest == nil {
+			continue
+		}
+		for _, nic := range vm.Guest.Net {
+			gna := GuestNetworkAddresses{
+				VirtualMachine: vm.Name,
+				Network:        nic.Network,
+				IPAddresses:    parseIPAddresses(nic.IpAddress...),
+			}
+			if len(gna.IPAddresses) > 0 {
+				addresses = append(addresses, gna)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
 func (m *Metadata) GetPortGroupVlanFromMoRef(networks []types.ManagedObjectReference, server string) ([]int32, error) {
 	sess, err := m.Session(context.TODO(), server)
 
@@ -98,192 +149,239 @@ func (m *Metadata) GetTagCategories(server string) error {
 	return nil
 }
 
-func (m *Metadata) GetFailureDomainsViaTag(server string) (*[]v1.VSpherePlatformFailureDomainSpec, error) {
-	failureDomainMap := make(map[string]v1.VSpherePlatformFailureDomainSpec)
-
-	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
-	defer cancel()
+// taggedScope an object tagged with a region or zone and the inventory path it covers
+type taggedScope struct {
+	ref  types.ManagedObjectReference
+	path string
+	tag  string
+}
 
-	sess, err := m.Session(ctx, server)
+// taggedScopes returns the objects with a tag of the category, tags are read through the cache
+func (m *Metadata) taggedScopes(ctx context.Context, sess *session.Session, server, categoryName string) ([]taggedScope, error) {
+	category, err := m.getCategory(ctx, sess, server, categoryName)
 	if err != nil {
 		return nil, err
 	}
 
-	regionCategory, err := sess.TagManager.GetCategory(ctx, openshiftRegionTagCatName)
+	categoryTags, err := m.getTagsForCategory(ctx, sess, server, category.ID)
 	if err != nil {
 		return nil, err
 	}
-	zoneCategory, err := sess.TagManager.GetCategory(ctx, openshiftZoneTagCatName)
-	if err != nil {
-		return nil, err
+	if len(categoryTags) == 0 {
+		return nil, nil
+	}
+	tagIds := make([]string, len(categoryTags))
+	for i := range categoryTags {
+		tagIds[i] = categoryTags[i].ID
 	}
 
-	// Retrieve all the tags for openshift-region category
-	regionTags, err := sess.TagManager.GetTagsForCategory(ctx, regionCategory.ID)
+	attached, err := m.getAttachedObjectsOnTags(ctx, sess, server, tagIds)
 	if err != nil {
 		return nil, err
 	}
-	regionTagIds := make([]string, len(regionTags))
-	for i := 0; i < len(regionTags); i++ {
-		regionTagIds[i] = regionTags[i].ID
+
+	var scopes []taggedScope
+	for _, ao := range attached {
+		for _, obj := range ao.ObjectIDs {
+			ref := obj.Reference()
+			inventoryPath, err := find.InventoryPath(ctx, sess.Client.Client, ref)
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, taggedScope{ref: ref, path: inventoryPath, tag: ao.Tag.Name})
+		}
+	}
+	return scopes, nil
+}
+
+// closestScope returns the most specific scope of the managed object types containing the path
+func closestScope(scopes []taggedScope, inventoryPath string, moTypes ...string) (taggedScope, bool) {
+	var best taggedScope
+	found := false
+	for _, s := range scopes {
+		if !slices.Contains(moTypes, s.ref.Type) || !scopeContains(s.path, inventoryPath) {
+			continue
+		}
+		if !found || len(s.path) > len(best.path) {
+			best = s
+			found = true
+		}
 	}
+	return best, found
+}
+
+// GetFailureDomainsViaTag returns a failure domain per zone tagged cluster, or per cluster and zone
+// for host group zones, in a region. The tag categories and the tagged object types are read
+// from the TagLayout, the DRS groups of host group zones are available from HostGroupZone.
+func (m *Metadata) GetFailureDomainsViaTag(server string) (*[]v1.VSpherePlatformFailureDomainSpec, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
 
-	// Retrieve all the attached objects for openshift-region category
-	attachedRegionObjects, err := sess.TagManager.GetAttachedObjectsOnTags(ctx, regionTagIds)
+	sess, err := m.Session(ctx, server)
 	if err != nil {
 		return nil, err
 	}
 
-	// Retrieve all the tags for openshift-zone category
-	zoneTags, err := sess.TagManager.GetTagsForCategory(ctx, zoneCategory.ID)
+	layout := m.TagLayout
+
+	regionScopes, err := m.taggedScopes(ctx, sess, server, layout.RegionCategory)
+	if err != nil {
+		return nil, err
+	}
+	zoneScopes, err := m.taggedScopes(ctx, sess, server, layout.ZoneCategory)
 	if err != nil {
 		return nil, err
 	}
-	zoneTagIds := make([]string, len(zoneTags))
-	for i := 0; i < len(zoneTags); i++ {
-		zoneTagIds[i] = zoneTags[i].ID
+
+	var regionTypes []string
+	for _, t := range layout.RegionTypes {
+		regionTypes = append(regionTypes, regionObjectTypes[t])
+	}
+	var zoneTypes []string
+	hostGroups := false
+	for _, t := range layout.ZoneTypes {
+		if t == ZoneTypeHostGroup {
+			hostGroups = true
+			continue
+		}
+		zoneTypes = append(zoneTypes, zoneObjectTypes[t])
 	}
 
-	// Retrieve all the attached objects for openshift-zone category
-	attachedZoneObjects, err := sess.TagManager.GetAttachedObjectsOnTags(ctx, zoneTagIds)
+	datacenters, err := sess.Finder.DatacenterList(ctx, "/...")
 	if err != nil {
 		return nil, err
 	}
 
-	clusterTagMap := make(map[string]string)
+	var failureDomains []v1.VSpherePlatformFailureDomainSpec
+	for _, dcObj := range datacenters {
+		clusterObjects, err := sess.Finder.ClusterComputeResourceList(ctx, path.Join(dcObj.InventoryPath, "host", "..."))
+		if err != nil {
+			var notFound *find.NotFoundError
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return nil, err
+		}
 
-	for _, za := range attachedZoneObjects {
-		for _, zaObj := range za.ObjectIDs {
-			ref, err := sess.Finder.ObjectReference(ctx, zaObj.Reference())
+		for _, clusterObj := range clusterObjects {
+			region, ok := closestScope(regionScopes, clusterObj.InventoryPath, regionTypes...)
+			if !ok {
+				continue
+			}
+
+			key := fmt.Sprintf("%s-%s-%s", server, dcObj.Name(), clusterObj.Name())
+
+			zones := make(map[string]string)
+			if hostGroups {
+				if zoneHosts := hostZones(zoneScopes, clusterObj.InventoryPath); len(zoneHosts) > 0 {
+					groups, err := drsHostGroupZones(ctx, clusterObj, server, zoneHosts)
+					if err != nil {
+						return nil, err
+					}
+					for zone, hgz := range groups {
+						name := fmt.Sprintf("%s-%s", key, zone)
+						zones[name] = zone
+						m.hostGroupZones[name] = hgz
+					}
+				}
+			}
+			if len(zones) == 0 {
+				zone, ok := closestScope(zoneScopes, clusterObj.InventoryPath, zoneTypes...)
+				if !ok {
+					continue
+				}
+				// zone tagged folders must be in the host folder of the datacenter
+				if zone.ref.Type == folderType && !scopeContains(path.Join(dcObj.InventoryPath, "host"), zone.path) {
+					continue
+				}
+				zones[key] = zone.tag
+			}
+
+			topology, err := clusterTopology(ctx, sess, clusterObj, m.PortGroupSubstring)
 			if err != nil {
 				return nil, err
 			}
-			// we only care about cluster objects
-			if cObj, ok := ref.(*object.ClusterComputeResource); ok {
-				clusterTagMap[cObj.Reference().Value] = za.Tag.Name
+			topology.Datacenter = dcObj.InventoryPath
+
+			for name, zone := range zones {
+				failureDomains = append(failureDomains, v1.VSpherePlatformFailureDomainSpec{
+					Region:   region.tag,
+					Zone:     zone,
+					Server:   server,
+					Name:     name,
+					Topology: topology,
+				})
 			}
 		}
 	}
 
-	// loop through the region tags Attached Objects (tags.AttachedObjects)
-	for _, ra := range attachedRegionObjects {
+	sort.Slice(failureDomains, func(i, j int) bool {
+		return failureDomains[i].Name < failureDomains[j].Name
+	})
+
+	return &failureDomains, nil
+}
 
-		// Loop though the []Reference (vCenter Objects tagged)
-		for _, raObj := range ra.ObjectIDs {
+// clusterTopology returns the port groups matching the substring and the datastores shared by every host of the cluster
+func clusterTopology(ctx context.Context, sess *session.Session, clusterObj *object.ClusterComputeResource, portGroupSubstring string) (v1.VSpherePlatformTopology, error) {
+	var topology v1.VSpherePlatformTopology
+	datastore := make(map[types.ManagedObjectReference]bool)
 
-			// We need the object.Reference to get InventoryPath
-			ref, err := sess.Finder.ObjectReference(ctx, raObj.Reference())
-			if err != nil {
-				return nil, err
-			}
+	var cMo mo.ClusterComputeResource
+	// retrieve the child fields of the Cluster
+	if err := clusterObj.Properties(ctx, clusterObj.Reference(), []string{"host", "datastore", "network"}, &cMo); err != nil {
+		return topology, err
+	}
 
-			// Since currently we only have a region as a datacenter
-			// we only want datacenter objects
-			if dcObj, ok := ref.(*object.Datacenter); ok {
+	networks := make([]string, 0, len(cMo.Network))
 
-				// Retrieve all the child clusters
-				clusterObjects, err := sess.Finder.ClusterComputeResourceList(ctx, path.Join(dcObj.InventoryPath, "host", "..."))
-				if err != nil {
-					return nil, err
-				}
+	for _, n := range cMo.Network {
+		objref, err := sess.Finder.ObjectReference(ctx, n.Reference())
+		if err != nil {
+			return topology, err
+		}
+		if objDvPg, ok := objref.(*object.DistributedVirtualPortgroup); ok {
 
-				// Loop over all the clusters available in the datacenter
-				for _, clusterObj := range clusterObjects {
-					datastore := make(map[types.ManagedObjectReference]bool)
-
-					// defined above, the clusterTagMap contains the clusters that
-					// have been tagged with openshift-zone
-					if tagName, ok := clusterTagMap[clusterObj.Reference().Value]; ok {
-
-						var cMo mo.ClusterComputeResource
-						// retrieve the child fields of the Cluster
-						if err := clusterObj.Properties(ctx, clusterObj.Reference(), []string{"host", "datastore", "network"}, &cMo); err != nil {
-							return nil, err
-						}
-
-						networks := make([]string, 0, len(cMo.Network))
-
-						for _, n := range cMo.Network {
-							var objref object.Reference
-							if objref, err = sess.Finder.ObjectReference(ctx, n.Reference()); err != nil {
-								return nil, err
-							}
-							if objDvPg, ok := objref.(*object.DistributedVirtualPortgroup); ok {
-
-								// todo: we only care about ci-vlan-#### port groups
-								// todo: though maybe it doesn't matter, we can remove after this returns
-
-								// todo: temporary to make it cleaner
-								if strings.Contains(objDvPg.InventoryPath, "ci-vlan") {
-									networks = append(networks, objDvPg.InventoryPath)
-								}
-							}
-						}
-
-						// Initialize the datastore map
-						for _, ds := range cMo.Datastore {
-							var dMo mo.Datastore
-							err := sess.PropertyCollector().RetrieveOne(ctx, ds, []string{"host"}, &dMo)
-							if err != nil {
-								return nil, err
-							}
-
-							datastore[ds] = false
-							if len(cMo.Host) == (len(dMo.Host)) {
-								datastore[ds] = true
-							}
-						}
-
-						datastorePaths := make([]string, 0, len(datastore))
-						for k, v := range datastore {
-							if v {
-								dsref, err := sess.Finder.ObjectReference(ctx, k)
-								if err != nil {
-									return nil, err
-								}
-
-								if dsObj, ok := dsref.(*object.Datastore); ok {
-									datastorePaths = append(datastorePaths, dsObj.InventoryPath)
-								}
-							}
-						}
-
-						joinedDSPaths := strings.Join(datastorePaths, ",")
-
-						clusterName := clusterObj.Name()
-						datacenterName := dcObj.Name()
-
-						key := fmt.Sprintf("%s-%s-%s", server, datacenterName, clusterName)
-
-						failureDomainMap[key] = v1.VSpherePlatformFailureDomainSpec{
-							Region: ra.Tag.Name,
-							Zone:   tagName,
-							Server: server,
-							Name:   key,
-							Topology: v1.VSpherePlatformTopology{
-								Datacenter:     dcObj.InventoryPath,
-								ComputeCluster: clusterObj.InventoryPath,
-								Networks:       networks,
-
-								Datastore:    joinedDSPaths,
-								Folder:       "",
-								Template:     "",
-								ResourcePool: "",
-							},
-						}
-					}
-				}
+			// only the CI port groups are networks of the failure domain
+			if strings.Contains(objDvPg.InventoryPath, portGroupSubstring) {
+				networks = append(networks, objDvPg.InventoryPath)
 			}
 		}
 	}
 
-	failureDomains := make([]v1.VSpherePlatformFailureDomainSpec, 0, len(failureDomainMap))
+	// Initialize the datastore map
+	for _, ds := range cMo.Datastore {
+		var dMo mo.Datastore
+		err := sess.PropertyCollector().RetrieveOne(ctx, ds, []string{"host"}, &dMo)
+		if err != nil {
+			return topology, err
+		}
 
-	for _, v := range failureDomainMap {
-		failureDomains = append(failureDomains, v)
+		datastore[ds] = false
+		if len(cMo.Host) == (len(dMo.Host)) {
+			datastore[ds] = true
+		}
 	}
 
-	return &failureDomains, nil
+	datastorePaths := make([]string, 0, len(datastore))
+	for k, v := range datastore {
+		if v {
+			dsref, err := sess.Finder.ObjectReference(ctx, k)
+			if err != nil {
+				return topology, err
+			}
+
+			if dsObj, ok := dsref.(*object.Datastore); ok {
+				datastorePaths = append(datastorePaths, dsObj.InventoryPath)
+			}
+		}
+	}
+
+	topology.ComputeCluster = clusterObj.InventoryPath
+	topology.Networks = networks
+	topology.Datastore = strings.Join(datastorePaths, ",")
+
+	return topology, nil
 }
 
 /*
@@ -0,0 +1,297 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+// defaultPortGroupPorts is the initial number of ports of a created port group, it expands automatically
+const defaultPortGroupPorts = 8
+
+// GetDistributedSwitch returns the distributed switch with the name
+func (m *Metadata) GetDistributedSwitch(server, name string) (*object.DistributedVirtualSwitch, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr := view.NewManager(sess.Client.Client)
+	kind := []string{"DistributedVirtualSwitch"}
+
+	v, err := mgr.CreateContainerView(ctx, sess.ServiceContent.RootFolder, kind, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var switches []mo.DistributedVirtualSwitch
+	if err := v.Retrieve(ctx, kind, []string{"name"}, &switches); err != nil {
+		return nil, err
+	}
+
+	for _, s := range switches {
+		if s.Name == name {
+			dvs := object.NewDistributedVirtualSwitch(sess.Client.Client, s.Self)
+			dvs.InventoryPath = name
+			return dvs, nil
+		}
+	}
+	return nil, fmt.Errorf("distributed switch %s not found on %s", name, server)
+}
+
+// CreatePortGroup creates an early binding port group on the vlan of the switch
+func (m *Metadata) CreatePortGroup(server string, dvs *object.DistributedVirtualSwitch, name string, vlanId int32) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	if _, err := m.Session(ctx, server); err != nil {
+		return err
+	}
+
+	spec := types.DVPortgroupConfigSpec{
+		Name:       name,
+		Type:       string(types.DistributedVirtualPortgroupPortgroupTypeEarlyBinding),
+		NumPorts:   defaultPortGroupPorts,
+		AutoExpand: types.NewBool(true),
+		DefaultPortConfig: &types.VMwareDVSPortSetting{
+			Vlan: &types.VmwareDistributedVirtualSwitchVlanIdSpec{
+				VlanId: vlanId,
+			},
+		},
+	}
+
+	task, err := dvs.AddPortgroup(ctx, []types.DVPortgroupConfigSpec{spec})
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// SwitchHost a host of a cluster that is not a member of a switch
+type SwitchHost struct {
+	Name      string
+	Reference types.ManagedObjectReference
+	// FreePnics the physical nics of the host not used by a standard or distributed switch
+	FreePnics []string
+}
+
+// GetHostsMissingFromSwitch returns the hosts of the clusters that are not members of the switch
+func (m *Metadata) GetHostsMissingFromSwitch(server string, dvs *object.DistributedVirtualSwitch, clusters []*object.ClusterComputeResource) ([]SwitchHost, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var dvsMo mo.DistributedVirtualSwitch
+	if err := dvs.Properties(ctx, dvs.Reference(), []string{"summary.hostMember"}, &dvsMo); err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(dvsMo.Summary.HostMember))
+	for _, h := range dvsMo.Summary.HostMember {
+		members[h.Value] = true
+	}
+
+	var missing []SwitchHost
+	seen := make(map[string]bool)
+	for _, cluster := range clusters {
+		var cMo mo.ClusterComputeResource
+		if err := cluster.Properties(ctx, cluster.Reference(), []string{"host"}, &cMo); err != nil {
+			return nil, err
+		}
+		if len(cMo.Host) == 0 {
+			continue
+		}
+
+		var hostMos []mo.HostSystem
+		if err := sess.Retrieve(ctx, cMo.Host, []string{"name", "config.network"}, &hostMos); err != nil {
+			return nil, err
+		}
+		for _, h := range hostMos {
+			if members[h.Self.Value] || seen[h.Self.Value] {
+				continue
+			}
+			seen[h.Self.Value] = true
+			missing = append(missing, SwitchHost{Name: h.Name, Reference: h.Self, FreePnics: freePnics(h)})
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return missing, nil
+}
+
+// freePnics returns the devices of the physical nics that are not an uplink of a switch
+func freePnics(h mo.HostSystem) []string {
+	if h.Config == nil || h.Config.Network == nil {
+		return nil
+	}
+	network := h.Config.Network
+
+	used := make(map[string]bool)
+	for _, vs := range network.Vswitch {
+		for _, key := range vs.Pnic {
+			used[key] = true
+		}
+	}
+	for _, ps := range network.ProxySwitch {
+		for _, key := range ps.Pnic {
+			used[key] = true
+		}
+	}
+
+	var free []string
+	for _, pnic := range network.Pnic {
+		if !used[pnic.Key] {
+			free = append(free, pnic.Device)
+		}
+	}
+	return free
+}
+
+// AddSwitchHosts adds the hosts to the switch with the physical nics as uplinks
+func (m *Metadata) AddSwitchHosts(server string, dvs *object.DistributedVirtualSwitch, hosts []SwitchHost, uplinks []string) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	if _, err := m.Session(ctx, server); err != nil {
+		return err
+	}
+
+	var dvsMo mo.DistributedVirtualSwitch
+	if err := dvs.Properties(ctx, dvs.Reference(), []string{"config"}, &dvsMo); err != nil {
+		return err
+	}
+
+	backing := &types.DistributedVirtualSwitchHostMemberPnicBacking{}
+	for _, device := range uplinks {
+		backing.PnicSpec = append(backing.PnicSpec, types.DistributedVirtualSwitchHostMemberPnicSpec{PnicDevice: device})
+	}
+
+	spec := &types.DVSConfigSpec{
+		ConfigVersion: dvsMo.Config.GetDVSConfigInfo().ConfigVersion,
+	}
+	for _, h := range hosts {
+		spec.Host = append(spec.Host, types.DistributedVirtualSwitchHostMemberConfigSpec{
+			Operation: string(types.ConfigSpecOperationAdd),
+			Host:      h.Reference,
+			Backing:   backing,
+		})
+	}
+
+	task, err := dvs.Reconfigure(ctx, spec)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// PlanPortGroups adds a port group named prefix<vlan> per vlan on the switch to the plan, port
+// groups that exist on the vlan are left alone. The hosts of the tagged clusters that are not
+// members of the switch are added with the uplinks, hosts without the uplinks free, or every host
+// when no uplinks are given, are left out of the plan and reported.
+func (m *Metadata) PlanPortGroups(p *plan.Plan, server, switchName, prefix string, vlans []int32, uplinks []string) error {
+	dvs, err := m.GetDistributedSwitch(server, switchName)
+	if err != nil {
+		return err
+	}
+
+	existing, err := m.GetDistributedPortGroups(server, "")
+	if err != nil {
+		return err
+	}
+	portGroups := make(map[string]mo.DistributedVirtualPortgroup, len(existing))
+	for _, pg := range existing {
+		portGroups[pg.Config.Name] = pg
+	}
+
+	vlans = slices.Clone(vlans)
+	slices.Sort(vlans)
+	for _, vlanId := range slices.Compact(vlans) {
+		name := fmt.Sprintf("%s%d", prefix, vlanId)
+		if pg, ok := portGroups[name]; ok {
+			if existingVlan, ok := PortGroupVlanId(pg); !ok || existingVlan != vlanId {
+				log.Printf("WARNING: port group %s already exists on %s with another vlan, skipping vlan %d", name, server, vlanId)
+			}
+			continue
+		}
+
+		p.Add(fmt.Sprintf("%s: create port group %s on switch %s with vlan %d", server, name, switchName, vlanId), func() error {
+			return m.CreatePortGroup(server, dvs, name, vlanId)
+		})
+	}
+
+	failureDomains, err := m.GetFailureDomainsViaTag(server)
+	if failureDomains == nil {
+		if err != nil {
+			log.Printf("WARNING: No failure domains found for %s, %s", server, err)
+		}
+		return nil
+	}
+
+	clusters := make([]*object.ClusterComputeResource, 0, len(*failureDomains))
+	clusterPaths := make(map[string]bool)
+	for _, fd := range *failureDomains {
+		// host group failure domains share their cluster
+		if clusterPaths[fd.Topology.ComputeCluster] {
+			continue
+		}
+		clusterPaths[fd.Topology.ComputeCluster] = true
+
+		cObj, err := m.GetClusterByPath(fd.Server, fd.Topology.ComputeCluster)
+		if err != nil {
+			return err
+		}
+		clusters = append(clusters, cObj)
+	}
+
+	missing, err := m.GetHostsMissingFromSwitch(server, dvs, clusters)
+	if err != nil {
+		return err
+	}
+
+	var hosts []SwitchHost
+	var hostNames []string
+	for _, h := range missing {
+		switch {
+		case len(uplinks) == 0:
+			log.Printf("WARNING: host %s of a tagged cluster is not a member of switch %s, set the uplinks to add it", h.Name, switchName)
+		case !containsAll(h.FreePnics, uplinks):
+			log.Printf("WARNING: host %s of a tagged cluster is not a member of switch %s and the uplinks %s are not free on it, free nics: %s", h.Name, switchName, strings.Join(uplinks, ", "), strings.Join(h.FreePnics, ", "))
+		default:
+			hosts = append(hosts, h)
+			hostNames = append(hostNames, h.Name)
+		}
+	}
+	if len(hosts) > 0 {
+		p.Add(fmt.Sprintf("%s: add hosts %s of the tagged clusters to switch %s with uplinks %s", server, strings.Join(hostNames, ", "), switchName, strings.Join(uplinks, ", ")), func() error {
+			return m.AddSwitchHosts(server, dvs, hosts, uplinks)
+		})
+	}
+
+	return nil
+}
+
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		if !slices.Contains(values, w) {
+			return false
+		}
+	}
+	return true
+}
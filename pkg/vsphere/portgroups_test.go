@@ -0,0 +1,221 @@
+package vsphere
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+// newSimulatorMetadata returns metadata with the credentials of the simulator of the client
+func newSimulatorMetadata(t *testing.T, c *vim25.Client) (*Metadata, string) {
+	t.Helper()
+	server := c.URL().Host
+	password, _ := simulator.DefaultLogin.Password()
+
+	m := NewMetadata()
+	if _, err := m.AddVCenterCredential(server, VCenterCredential{Username: simulator.DefaultLogin.Username(), Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	return m, server
+}
+
+// tagSimulatorFailureDomain tags the first datacenter and cluster of the simulator as a region and zone
+func tagSimulatorFailureDomain(ctx context.Context, t *testing.T, m *Metadata, server string) {
+	t.Helper()
+	sess, err := m.Session(ctx, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := sess.Finder.Datacenter(ctx, "DC0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := sess.Finder.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &plan.Plan{}
+	err = m.PlanTopologyTags(p, VCenterTopology{
+		Server: server,
+		Regions: []RegionTopology{{
+			Name:       "us-east",
+			Datacenter: dc.InventoryPath,
+			Zones:      []ZoneTopology{{Name: "us-east-1a", Cluster: cluster.InventoryPath}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createSimulatorSwitch creates a distributed switch without hosts in the first datacenter
+func createSimulatorSwitch(ctx context.Context, t *testing.T, c *vim25.Client, name string) {
+	t.Helper()
+	dc, err := find.NewFinder(c).Datacenter(ctx, "DC0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := folders.NetworkFolder.CreateDVS(ctx, types.DVSCreateSpec{
+		ConfigSpec: &types.VMwareDVSConfigSpec{DVSConfigSpec: types.DVSConfigSpec{Name: name}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func descriptions(p *plan.Plan) []string {
+	var d []string
+	for _, a := range p.Actions {
+		d = append(d, a.Description)
+	}
+	return d
+}
+
+func TestPlanPortGroups(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		m, server := newSimulatorMetadata(t, c)
+		defer m.Logout()
+		tagSimulatorFailureDomain(ctx, t, m, server)
+		createSimulatorSwitch(ctx, t, c, "dvs-ci")
+
+		dvs, err := m.GetDistributedSwitch(server, "dvs-ci")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cluster, err := m.GetClusterByPath(server, "/DC0/host/DC0_C0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every host of the tagged cluster is missing from the new switch, vmnic0 is the uplink of vSwitch0
+		missing, err := m.GetHostsMissingFromSwitch(server, dvs, []*object.ClusterComputeResource{cluster, cluster})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(missing) != 3 {
+			t.Fatalf("expected the 3 hosts of the cluster to be missing, got %+v", missing)
+		}
+		for _, h := range missing {
+			if !slices.Equal(h.FreePnics, []string{"vmnic1"}) {
+				t.Errorf("host %s: expected free nics [vmnic1], got %v", h.Name, h.FreePnics)
+			}
+		}
+
+		// a port group of the name on another vlan is left alone
+		if err := m.CreatePortGroup(server, dvs, "ci-vlan-1236", 99); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			name     string
+			vlans    []int32
+			uplinks  []string
+			expected []string
+		}{
+			{
+				name:    "port groups without uplinks",
+				vlans:   []int32{1235, 1234, 1236, 1234},
+				uplinks: nil,
+				expected: []string{
+					server + ": create port group ci-vlan-1234 on switch dvs-ci with vlan 1234",
+					server + ": create port group ci-vlan-1235 on switch dvs-ci with vlan 1235",
+				},
+			},
+			{
+				name:    "created port groups and uplinks in use",
+				vlans:   []int32{1234, 1235},
+				uplinks: []string{"vmnic0"},
+			},
+			{
+				name:    "hosts with free uplinks",
+				vlans:   []int32{1234, 1235},
+				uplinks: []string{"vmnic1"},
+				expected: []string{
+					server + ": add hosts DC0_C0_H0, DC0_C0_H1, DC0_C0_H2 of the tagged clusters to switch dvs-ci with uplinks vmnic1",
+				},
+			},
+			{
+				name:    "second run",
+				vlans:   []int32{1234, 1235},
+				uplinks: []string{"vmnic1"},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				p := &plan.Plan{}
+				if err := m.PlanPortGroups(p, server, "dvs-ci", "ci-vlan-", tc.vlans, tc.uplinks); err != nil {
+					t.Fatal(err)
+				}
+				if d := descriptions(p); !slices.Equal(d, tc.expected) {
+					t.Fatalf("expected plan %q, got %q", tc.expected, d)
+				}
+				if err := p.Apply(); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+
+		portGroups, err := m.GetDistributedPortGroups(server, "ci-vlan-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		vlans := make(map[string]int32)
+		for _, pg := range portGroups {
+			vlanId, ok := PortGroupVlanId(pg)
+			if !ok {
+				t.Errorf("port group %s is not on a single vlan", pg.Config.Name)
+			}
+			if pg.Config.DistributedVirtualSwitch == nil || pg.Config.DistributedVirtualSwitch.Value != dvs.Reference().Value {
+				t.Errorf("port group %s is not on switch dvs-ci", pg.Config.Name)
+			}
+			vlans[pg.Config.Name] = vlanId
+		}
+		expected := map[string]int32{"ci-vlan-1234": 1234, "ci-vlan-1235": 1235, "ci-vlan-1236": 99}
+		if len(vlans) != len(expected) {
+			t.Errorf("expected port groups %v, got %v", expected, vlans)
+		}
+		for name, vlanId := range expected {
+			if vlans[name] != vlanId {
+				t.Errorf("expected port group %s on vlan %d, got %d", name, vlanId, vlans[name])
+			}
+		}
+
+		missing, err = m.GetHostsMissingFromSwitch(server, dvs, []*object.ClusterComputeResource{cluster})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(missing) != 0 {
+			t.Errorf("expected every host of the cluster on the switch, missing %+v", missing)
+		}
+
+		// the port groups are available in the cluster once its hosts are members
+		networks, err := m.GetClusterNetworks(server, cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pg := range portGroups {
+			if !networks[pg.Self.Value] {
+				t.Errorf("port group %s is not available in the cluster", pg.Config.Name)
+			}
+		}
+	})
+}
@@ -3,10 +3,14 @@ package vsphere
 import (
 	"context"
 	"fmt"
+	"log"
 	"net"
+	"time"
 
 	"github.com/vmware/govmomi/vapi/tags"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
 )
 
 type ClusterContext struct {
@@ -31,12 +35,18 @@ type VCenterContext struct {
 	TagCategories      []tags.Category
 }
 
-// VCenterCredential contains the vCenter username and password.
+// VCenterCredential contains the vCenter username and password, and optionally a CA bundle
+// and a pinned SHA-256 certificate thumbprint.
 type VCenterCredential struct {
-	Username string
-	Password string
+	Username   string
+	Password   string
+	CAFile     string `json:",omitempty"`
+	Thumbprint string `json:",omitempty"`
 }
 
+// DefaultPortGroupSubstring the substring of the CI port groups
+const DefaultPortGroupSubstring = "ci-vlan"
+
 // Metadata holds vcenter stuff.
 type Metadata struct {
 	sessions    map[string]*session.Session
@@ -45,6 +55,26 @@ type Metadata struct {
 	VCenterContexts map[string]VCenterContext
 
 	VCenterCredentials map[string]VCenterCredential
+
+	// Cache optionally persists tag and category lookups between runs
+	Cache *cache.Cache
+
+	// TagLayout the tag categories and tagged object types failure domains are read from
+	TagLayout TagLayout
+
+	// PortGroupSubstring selects the port groups of the failure domain networks
+	PortGroupSubstring string
+
+	// SessionCacheDir optionally persists the session cookies of each vCenter between runs
+	SessionCacheDir string
+
+	// KeepAlive is the idle time between keep alive requests, zero disables them
+	KeepAlive time.Duration
+
+	keepAliveStops map[string][]func()
+
+	// hostGroupZones the host group zones of the failure domains read so far by failure domain name
+	hostGroupZones map[string]HostGroupZone
 }
 
 // NewMetadata initializes a new Metadata object.
@@ -54,6 +84,10 @@ func NewMetadata() *Metadata {
 		credentials:        make(map[string]*session.Params),
 		VCenterContexts:    make(map[string]VCenterContext),
 		VCenterCredentials: make(map[string]VCenterCredential),
+		TagLayout:          DefaultTagLayout(),
+		PortGroupSubstring: DefaultPortGroupSubstring,
+		hostGroupZones:     make(map[string]HostGroupZone),
+		keepAliveStops:     make(map[string][]func()),
 	}
 }
 
@@ -79,11 +113,11 @@ func (m *Metadata) AddCredentials(server, username, password string) (*session.P
 	}
 
 	// We need the ip address of vCenter to determine which IBM subnet it is in, then we can determine
-	// the physical datacenter and pod vCenter is in.
-	// TODO: replace with call to vCenter APIs?
+	// the physical datacenter and pod vCenter is in. vCenters behind NAT or split DNS may not
+	// resolve, the other location strategies use the vCenter APIs instead.
 	ipAddrs, err := net.LookupIP(server)
 	if err != nil {
-		return nil, err
+		log.Printf("WARNING: unable to resolve vCenter %s: %v", server, err)
 	}
 
 	m.VCenterContexts[server] = VCenterContext{
@@ -94,6 +128,12 @@ func (m *Metadata) AddCredentials(server, username, password string) (*session.P
 	return m.credentials[server], nil
 }
 
+// AddVCenterCredential adds the credential and TLS settings of the vCenter
+func (m *Metadata) AddVCenterCredential(server string, credential VCenterCredential) (*session.Params, error) {
+	m.VCenterCredentials[server] = credential
+	return m.AddCredentials(server, credential.Username, credential.Password)
+}
+
 // Session returns a session from unlockedSession based on the server (vCenter URL).
 func (m *Metadata) Session(ctx context.Context, server string) (*session.Session, error) {
 	// m.sessions is not stored in the json state file - there is no real reason to do this
@@ -108,25 +148,25 @@ func (m *Metadata) Session(ctx context.Context, server string) (*session.Session
 
 func (m *Metadata) unlockedSession(ctx context.Context, server string) (*session.Session, error) {
 	var err error
-	var ok bool
-	var params *session.Params
 
-	if params, ok = m.credentials[server]; !ok {
-		if creds, ok := m.VCenterCredentials[server]; ok {
-			params, err = m.AddCredentials(server, creds.Username, creds.Password)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, fmt.Errorf("credentials for %s not found", server)
+	creds, ok := m.VCenterCredentials[server]
+	if !ok {
+		return nil, fmt.Errorf("credentials for %s not found", server)
+	}
+	if _, ok := m.credentials[server]; !ok {
+		if _, err = m.AddCredentials(server, creds.Username, creds.Password); err != nil {
+			return nil, err
 		}
 	}
+	if m.keepAliveStops == nil {
+		m.keepAliveStops = make(map[string][]func())
+	}
 
 	// if nil we haven't created a session
 	if _, ok := m.sessions[server]; ok {
-		// is the session still valid? if not re-run GetOrCreate.
+		// is the session still valid? if not create a new one.
 		if !m.sessions[server].Valid() {
-			m.sessions[server], err = session.GetOrCreate(ctx, params)
+			m.sessions[server], err = m.newSession(ctx, server, creds)
 			if err != nil {
 				return nil, err
 			}
@@ -135,6 +175,10 @@ func (m *Metadata) unlockedSession(ctx context.Context, server string) (*session
 	}
 
 	// If we have gotten here there is no session for the server name, create.
-	m.sessions[server], err = session.GetOrCreate(ctx, params)
-	return m.sessions[server], err
+	sess, err := m.newSession(ctx, server, creds)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[server] = sess
+	return sess, nil
 }
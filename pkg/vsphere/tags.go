@@ -0,0 +1,107 @@
+package vsphere
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/cache"
+)
+
+// cachedAttachedObjects is the cacheable form of tags.AttachedObjects, which
+// loses the tag and object types when marshalled.
+type cachedAttachedObjects struct {
+	Tag       tags.Tag                       `json:"tag"`
+	ObjectIDs []types.ManagedObjectReference `json:"objectIds"`
+}
+
+func (m *Metadata) putCache(kind cache.Kind, key string, v any) {
+	if err := m.Cache.Put(kind, key, v); err != nil {
+		log.Printf("WARNING: unable to cache %s %s: %v", kind, key, err)
+	}
+}
+
+// getCategory returns the tag category by name or id
+func (m *Metadata) getCategory(ctx context.Context, sess *session.Session, server, name string) (*tags.Category, error) {
+	key := server + "/" + name
+
+	var category tags.Category
+	if m.Cache.Get(cache.KindVSphereTagCategories, key, &category) {
+		return &category, nil
+	}
+
+	c, err := sess.TagManager.GetCategory(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.putCache(cache.KindVSphereTagCategories, key, c)
+	return c, nil
+}
+
+func (m *Metadata) getTagsForCategory(ctx context.Context, sess *session.Session, server, categoryID string) ([]tags.Tag, error) {
+	key := server + "/" + categoryID
+
+	var categoryTags []tags.Tag
+	if m.Cache.Get(cache.KindVSphereTags, key, &categoryTags) {
+		return categoryTags, nil
+	}
+
+	categoryTags, err := sess.TagManager.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.putCache(cache.KindVSphereTags, key, categoryTags)
+	return categoryTags, nil
+}
+
+func (m *Metadata) getAttachedObjectsOnTags(ctx context.Context, sess *session.Session, server string, tagIDs []string) ([]tags.AttachedObjects, error) {
+	sortedIDs := append([]string(nil), tagIDs...)
+	sort.Strings(sortedIDs)
+	key := server + "/" + strings.Join(sortedIDs, ",")
+
+	var cached []cachedAttachedObjects
+	if m.Cache.Get(cache.KindVSphereAttachedObjects, key, &cached) {
+		attached := make([]tags.AttachedObjects, 0, len(cached))
+		for _, c := range cached {
+			tag := c.Tag
+			ao := tags.AttachedObjects{
+				TagID: tag.ID,
+				Tag:   &tag,
+			}
+			for _, ref := range c.ObjectIDs {
+				ao.ObjectIDs = append(ao.ObjectIDs, ref)
+			}
+			attached = append(attached, ao)
+		}
+		return attached, nil
+	}
+
+	attached, err := sess.TagManager.GetAttachedObjectsOnTags(ctx, tagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	cached = make([]cachedAttachedObjects, 0, len(attached))
+	for _, ao := range attached {
+		c := cachedAttachedObjects{
+			Tag: tags.Tag{ID: ao.TagID},
+		}
+		if ao.Tag != nil {
+			c.Tag = *ao.Tag
+		}
+		for _, ref := range ao.ObjectIDs {
+			c.ObjectIDs = append(c.ObjectIDs, ref.Reference())
+		}
+		cached = append(cached, c)
+	}
+
+	m.putCache(cache.KindVSphereAttachedObjects, key, cached)
+	return attached, nil
+}
@@ -0,0 +1,297 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+const (
+	categoryCardinalitySingle = "SINGLE"
+)
+
+// Topology the regions and zones declared per vCenter
+type Topology struct {
+	VCenters []VCenterTopology `json:"vcenters"`
+}
+
+// VCenterTopology the regions of a vCenter
+type VCenterTopology struct {
+	Server  string           `json:"server"`
+	Regions []RegionTopology `json:"regions"`
+}
+
+// RegionTopology a region tag attached to a datacenter and the zones in it
+type RegionTopology struct {
+	Name string `json:"name"`
+	// Datacenter inventory path, e.g. /dc1
+	Datacenter string         `json:"datacenter"`
+	Zones      []ZoneTopology `json:"zones"`
+}
+
+// ZoneTopology a zone tag attached to a cluster
+type ZoneTopology struct {
+	Name string `json:"name"`
+	// Cluster inventory path, e.g. /dc1/host/cluster1
+	Cluster string `json:"cluster"`
+}
+
+// ReadTopology parses and validates the topology file
+func ReadTopology(fileName string) (*Topology, error) {
+	var topology Topology
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(b, &topology); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+
+	servers := make(map[string]bool)
+	for _, vc := range topology.VCenters {
+		if vc.Server == "" {
+			return nil, fmt.Errorf("%s: a vcenter has no server", fileName)
+		}
+		if servers[vc.Server] {
+			return nil, fmt.Errorf("%s: vcenter %s is declared more than once", fileName, vc.Server)
+		}
+		servers[vc.Server] = true
+
+		datacenters := make(map[string]bool)
+		clusters := make(map[string]bool)
+		for _, r := range vc.Regions {
+			if r.Name == "" || r.Datacenter == "" {
+				return nil, fmt.Errorf("%s: a region of %s has no name or datacenter", fileName, vc.Server)
+			}
+			if datacenters[r.Datacenter] {
+				return nil, fmt.Errorf("%s: datacenter %s of %s is in more than one region", fileName, r.Datacenter, vc.Server)
+			}
+			datacenters[r.Datacenter] = true
+
+			for _, z := range r.Zones {
+				if z.Name == "" || z.Cluster == "" {
+					return nil, fmt.Errorf("%s: a zone of region %s has no name or cluster", fileName, r.Name)
+				}
+				if clusters[z.Cluster] {
+					return nil, fmt.Errorf("%s: cluster %s of %s is in more than one zone", fileName, z.Cluster, vc.Server)
+				}
+				clusters[z.Cluster] = true
+			}
+		}
+	}
+
+	return &topology, nil
+}
+
+// tagBinding a tag that is attached to an object
+type tagBinding struct {
+	tag  string
+	ref  types.ManagedObjectReference
+	path string
+}
+
+// PlanTopologyTags adds the changes making the region and zone tags of the server match
+// the topology to the plan. Missing categories and tags are created, declared tags are
+// attached and tags of the categories attached to other datacenters and clusters are detached.
+func (m *Metadata) PlanTopologyTags(p *plan.Plan, topology VCenterTopology) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	sess, err := m.Session(ctx, topology.Server)
+	if err != nil {
+		return err
+	}
+
+	var regions, zones []tagBinding
+	for _, r := range topology.Regions {
+		dc, err := sess.Finder.Datacenter(ctx, r.Datacenter)
+		if err != nil {
+			return err
+		}
+		regions = append(regions, tagBinding{tag: r.Name, ref: dc.Reference(), path: dc.InventoryPath})
+
+		for _, z := range r.Zones {
+			cluster, err := sess.Finder.ClusterComputeResource(ctx, z.Cluster)
+			if err != nil {
+				return err
+			}
+			zones = append(zones, tagBinding{tag: z.Name, ref: cluster.Reference(), path: cluster.InventoryPath})
+		}
+	}
+
+	if err := m.planCategoryTags(ctx, sess, p, topology.Server, m.TagLayout.RegionCategory, datacenterType, regions); err != nil {
+		return err
+	}
+	return m.planCategoryTags(ctx, sess, p, topology.Server, m.TagLayout.ZoneCategory, clusterType, zones)
+}
+
+func (m *Metadata) planCategoryTags(ctx context.Context, sess *session.Session, p *plan.Plan, server, categoryName, objectType string, bindings []tagBinding) error {
+	tm := sess.TagManager
+	prefix := fmt.Sprintf("%s: category %s", server, categoryName)
+
+	categories, err := tm.GetCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	var category *tags.Category
+	for i := range categories {
+		if categories[i].Name == categoryName {
+			category = &categories[i]
+			break
+		}
+	}
+
+	// the ids of categories and tags created by the plan are only known once it is applied
+	var categoryID string
+	tagIDs := make(map[string]string)
+	attached := make(map[string]map[string]bool)
+	tagNames := make(map[string]string)
+
+	if category == nil {
+		p.Add(fmt.Sprintf("%s: create with %s cardinality for %s objects", prefix, categoryCardinalitySingle, objectType), func() error {
+			return withTimeout(func(ctx context.Context) error {
+				var err error
+				categoryID, err = tm.CreateCategory(ctx, &tags.Category{
+					Name:            categoryName,
+					Description:     "OpenShift failure domain " + objectType,
+					Cardinality:     categoryCardinalitySingle,
+					AssociableTypes: []string{objectType},
+				})
+				return err
+			})
+		})
+	} else {
+		categoryID = category.ID
+
+		if category.Cardinality != categoryCardinalitySingle {
+			return fmt.Errorf("%s has %s cardinality, it must be %s and can only be changed by recreating it", prefix, category.Cardinality, categoryCardinalitySingle)
+		}
+		if len(category.AssociableTypes) > 0 && !slices.Contains(category.AssociableTypes, objectType) {
+			update := *category
+			update.AssociableTypes = append(append([]string(nil), category.AssociableTypes...), objectType)
+			p.Add(fmt.Sprintf("%s: allow %s objects", prefix, objectType), func() error {
+				return withTimeout(func(ctx context.Context) error {
+					return tm.UpdateCategory(ctx, &update)
+				})
+			})
+		}
+
+		existing, err := tm.GetTagsForCategory(ctx, category.ID)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(existing))
+		for _, t := range existing {
+			tagIDs[t.Name] = t.ID
+			tagNames[t.ID] = t.Name
+			ids = append(ids, t.ID)
+		}
+
+		if len(ids) > 0 {
+			objects, err := tm.GetAttachedObjectsOnTags(ctx, ids)
+			if err != nil {
+				return err
+			}
+			for _, ao := range objects {
+				attached[ao.TagID] = make(map[string]bool)
+				for _, ref := range ao.ObjectIDs {
+					attached[ao.TagID][ref.Reference().String()] = true
+				}
+			}
+		}
+	}
+
+	declared := make(map[string]bool)
+	var missingTags []string
+	for _, b := range bindings {
+		declared[b.tag+"/"+b.ref.String()] = true
+		if _, ok := tagIDs[b.tag]; !ok && !slices.Contains(missingTags, b.tag) {
+			missingTags = append(missingTags, b.tag)
+		}
+	}
+
+	sort.Strings(missingTags)
+	for _, name := range missingTags {
+		p.Add(fmt.Sprintf("%s: create tag %s", prefix, name), func() error {
+			return withTimeout(func(ctx context.Context) error {
+				id, err := tm.CreateTag(ctx, &tags.Tag{
+					Name:       name,
+					CategoryID: categoryID,
+				})
+				tagIDs[name] = id
+				return err
+			})
+		})
+	}
+
+	// stale tags are detached first, the single cardinality refuses a second tag on an object
+	staleIDs := make([]string, 0, len(attached))
+	for id := range attached {
+		staleIDs = append(staleIDs, id)
+	}
+	sort.Strings(staleIDs)
+	for _, id := range staleIDs {
+		refs := make([]string, 0, len(attached[id]))
+		for ref := range attached[id] {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+
+		for _, ref := range refs {
+			if declared[tagNames[id]+"/"+ref] {
+				continue
+			}
+			// the tags of folders and hosts belong to the other layouts, e.g. host group zones
+			var moRef types.ManagedObjectReference
+			if !moRef.FromString(ref) || moRef.Type != objectType {
+				continue
+			}
+			p.Add(fmt.Sprintf("%s: detach tag %s from %s", prefix, tagNames[id], m.objectName(ctx, sess, moRef)), func() error {
+				return withTimeout(func(ctx context.Context) error {
+					return tm.DetachTag(ctx, id, moRef)
+				})
+			})
+		}
+	}
+
+	for _, b := range bindings {
+		if id, ok := tagIDs[b.tag]; ok && attached[id][b.ref.String()] {
+			continue
+		}
+		p.Add(fmt.Sprintf("%s: attach tag %s to %s", prefix, b.tag, b.path), func() error {
+			return withTimeout(func(ctx context.Context) error {
+				return tm.AttachTag(ctx, tagIDs[b.tag], b.ref)
+			})
+		})
+	}
+
+	return nil
+}
+
+// objectName returns the name and reference of the object
+func (m *Metadata) objectName(ctx context.Context, sess *session.Session, ref types.ManagedObjectReference) string {
+	name, err := object.NewCommon(sess.Client.Client, ref).ObjectName(ctx)
+	if err != nil {
+		return ref.String()
+	}
+	return fmt.Sprintf("%s (%s)", name, ref.String())
+}
+
+// withTimeout applies a single change of a plan within the timeout
+func withTimeout(f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	return f(ctx)
+}
@@ -0,0 +1,168 @@
+package vsphere
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+
+	"github.com/openshift-splat-team/vsphere-capacity-manager-data/pkg/plan"
+)
+
+func TestPlanTopologyTags(t *testing.T) {
+	model := simulator.VPX()
+	model.Cluster = 2
+
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		m, server := newSimulatorMetadata(t, c)
+		defer m.Logout()
+
+		topology := func(zone1b string) VCenterTopology {
+			return VCenterTopology{
+				Server: server,
+				Regions: []RegionTopology{{
+					Name:       "us-east",
+					Datacenter: "/DC0",
+					Zones: []ZoneTopology{
+						{Name: "us-east-1a", Cluster: "/DC0/host/DC0_C0"},
+						{Name: zone1b, Cluster: "/DC0/host/DC0_C1"},
+					},
+				}},
+			}
+		}
+
+		planTags := func(t *testing.T, topology VCenterTopology) []string {
+			t.Helper()
+			p := &plan.Plan{}
+			if err := m.PlanTopologyTags(p, topology); err != nil {
+				t.Fatal(err)
+			}
+			d := descriptions(p)
+			if err := p.Apply(); err != nil {
+				t.Fatal(err)
+			}
+			return d
+		}
+
+		t.Run("missing categories and tags", func(t *testing.T) {
+			prefix := server + ": category "
+			expected := []string{
+				prefix + "openshift-region: create with SINGLE cardinality for Datacenter objects",
+				prefix + "openshift-region: create tag us-east",
+				prefix + "openshift-region: attach tag us-east to /DC0",
+				prefix + "openshift-zone: create with SINGLE cardinality for ClusterComputeResource objects",
+				prefix + "openshift-zone: create tag us-east-1a",
+				prefix + "openshift-zone: create tag us-east-1b",
+				prefix + "openshift-zone: attach tag us-east-1a to /DC0/host/DC0_C0",
+				prefix + "openshift-zone: attach tag us-east-1b to /DC0/host/DC0_C1",
+			}
+			if d := planTags(t, topology("us-east-1b")); !slices.Equal(d, expected) {
+				t.Fatalf("expected plan %q, got %q", expected, d)
+			}
+
+			sess, err := m.Session(ctx, server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, objectType := range map[string]string{"openshift-region": datacenterType, "openshift-zone": clusterType} {
+				category, err := sess.TagManager.GetCategory(ctx, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if category.Cardinality != categoryCardinalitySingle || !slices.Equal(category.AssociableTypes, []string{objectType}) {
+					t.Errorf("category %s: expected SINGLE cardinality for %s, got %s for %v", name, objectType, category.Cardinality, category.AssociableTypes)
+				}
+			}
+		})
+
+		t.Run("second run", func(t *testing.T) {
+			if d := planTags(t, topology("us-east-1b")); len(d) != 0 {
+				t.Fatalf("expected an empty plan, got %q", d)
+			}
+		})
+
+		t.Run("changed zone", func(t *testing.T) {
+			d := planTags(t, topology("us-east-1c"))
+			if len(d) != 3 {
+				t.Fatalf("expected 3 changes, got %q", d)
+			}
+			if !strings.HasSuffix(d[0], "openshift-zone: create tag us-east-1c") {
+				t.Errorf("expected the tag to be created first, got %q", d[0])
+			}
+			if !strings.Contains(d[1], "openshift-zone: detach tag us-east-1b from DC0_C1 (ClusterComputeResource:") {
+				t.Errorf("expected the stale tag to be detached before the new one is attached, got %q", d[1])
+			}
+			if !strings.HasSuffix(d[2], "openshift-zone: attach tag us-east-1c to /DC0/host/DC0_C1") {
+				t.Errorf("expected the new tag to be attached last, got %q", d[2])
+			}
+
+			failureDomains, err := m.GetFailureDomainsViaTag(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zones := make(map[string]string)
+			for _, fd := range *failureDomains {
+				zones[fd.Topology.ComputeCluster] = fd.Zone
+			}
+			expected := map[string]string{"/DC0/host/DC0_C0": "us-east-1a", "/DC0/host/DC0_C1": "us-east-1c"}
+			if !maps.Equal(zones, expected) {
+				t.Errorf("expected zones %v, got %v", expected, zones)
+			}
+		})
+
+		t.Run("run after the change", func(t *testing.T) {
+			if d := planTags(t, topology("us-east-1c")); len(d) != 0 {
+				t.Fatalf("expected an empty plan, got %q", d)
+			}
+		})
+
+		t.Run("tags of other object types", func(t *testing.T) {
+			sess, err := m.Session(ctx, server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hosts, err := sess.Finder.HostSystemList(ctx, "/DC0/host/DC0_C0/*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			folder, err := sess.Finder.Folder(ctx, "/DC0/host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tc := range []struct {
+				category string
+				tag      string
+				ref      mo.Reference
+			}{
+				{"openshift-zone", "us-east-1a", hosts[0]},
+				{"openshift-region", "us-east", folder},
+			} {
+				category, err := sess.TagManager.GetCategory(ctx, tc.category)
+				if err != nil {
+					t.Fatal(err)
+				}
+				category.AssociableTypes = nil
+				if err := sess.TagManager.UpdateCategory(ctx, category); err != nil {
+					t.Fatal(err)
+				}
+				tag, err := sess.TagManager.GetTagForCategory(ctx, tc.tag, category.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := sess.TagManager.AttachTag(ctx, tag.ID, tc.ref); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// e.g. host group zones, only the tags of datacenters and clusters are managed
+			if d := planTags(t, topology("us-east-1c")); len(d) != 0 {
+				t.Fatalf("expected the tags of the host and folder to be kept, got %q", d)
+			}
+		})
+	}, model)
+}